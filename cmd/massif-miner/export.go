@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/callgraph"
+	"github.com/MohamTahaB/massif-miner/internal/callgrind"
+	"github.com/MohamTahaB/massif-miner/internal/chrometrace"
+	"github.com/MohamTahaB/massif-miner/internal/codec"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/report"
+	"github.com/MohamTahaB/massif-miner/internal/tabular"
+)
+
+// Define the options of the export formats. Formats ignore the options they do not need
+type exportOptions struct {
+	// Id of the detailed snapshot to export, the peak when negative, for the formats exporting a single heap tree
+	snapshot int
+	// Limits of the call graph formats
+	callgraph callgraph.Options
+	// Clock of the trace format
+	trace chrometrace.Options
+}
+
+// Define an export format, writing a whole log to the writer
+type exporter func(w io.Writer, log *outlog.OutLog, opts exportOptions) error
+
+// Export formats, by name
+var exporters = map[string]exporter{
+	"json": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return writeJSON(w, log, true)
+	},
+	"binary": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return codec.Encode(w, log)
+	},
+	"trace": func(w io.Writer, log *outlog.OutLog, opts exportOptions) error {
+		return chrometrace.Write(w, log, opts.trace)
+	},
+	"csv": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return tabular.WriteSnapshots(w, log, tabular.CSV)
+	},
+	"tsv": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return tabular.WriteSnapshots(w, log, tabular.TSV)
+	},
+	"nodes-csv": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return tabular.WriteNodes(w, log, tabular.CSV)
+	},
+	"nodes-tsv": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return tabular.WriteNodes(w, log, tabular.TSV)
+	},
+	"callgrind": func(w io.Writer, log *outlog.OutLog, opts exportOptions) error {
+		ss, err := detailedSnapshot(log, opts.snapshot)
+		if err != nil {
+			return err
+		}
+		return callgrind.Write(w, log, ss)
+	},
+	"dot": func(w io.Writer, log *outlog.OutLog, opts exportOptions) error {
+		ss, err := detailedSnapshot(log, opts.snapshot)
+		if err != nil {
+			return err
+		}
+		return callgraph.Build(ss, opts.callgraph).WriteDOT(w)
+	},
+	"svg": func(w io.Writer, log *outlog.OutLog, opts exportOptions) error {
+		ss, err := detailedSnapshot(log, opts.snapshot)
+		if err != nil {
+			return err
+		}
+		return callgraph.Build(ss, opts.callgraph).WriteSVG(w)
+	},
+	"markdown": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return report.WriteMarkdown(w, report.Build(log, report.DefaultSites))
+	},
+	"html": func(w io.Writer, log *outlog.OutLog, _ exportOptions) error {
+		return report.WriteHTML(w, report.Build(log, report.DefaultSites))
+	},
+}
+
+func exportFormats() string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func runExport(e *env, args []string) error {
+	fs := newFlagSet(e, "export", "[file]")
+	in := addInputFlags(fs)
+	format := fs.String("format", "json", "export format: "+exportFormats())
+	out := fs.String("o", "", "output file, stdout when empty or -")
+	id := fs.Int("snapshot", -1, "id of the detailed snapshot, the peak when negative, for the formats exporting a single heap tree")
+	nodeCount := fs.Int("nodecount", callgraph.DefaultNodeCount, "maximum number of functions of the call graph formats, no limit when not positive")
+	edgeFraction := fs.Float64("edgefraction", callgraph.DefaultEdgeFraction, "fraction of the heap under which the edges of the call graph formats are dropped")
+	ticks := fs.Float64("ticks-per-us", chrometrace.DefaultTicksPerMicrosecond, "instructions or bytes per microsecond of the trace format clock, for logs not timed in milliseconds")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path, err := inputPath(fs)
+	if err != nil {
+		return err
+	}
+
+	export, ok := exporters[*format]
+	if !ok {
+		return usageErrorf("unknown format %s, expected one of %s", *format, exportFormats())
+	}
+
+	log, err := in.load(e, path)
+	if err != nil {
+		return err
+	}
+
+	opts := exportOptions{
+		snapshot:  *id,
+		callgraph: callgraph.Options{NodeCount: *nodeCount, EdgeFraction: *edgeFraction},
+		trace:     chrometrace.Options{TicksPerMicrosecond: *ticks, PID: 1, TID: 1},
+	}
+
+	w, closeOutput, err := output(e, *out)
+	if err != nil {
+		return err
+	}
+	if err := export(w, log, opts); err != nil {
+		closeOutput()
+		return fmt.Errorf("export error: %w", err)
+	}
+	return closeOutput()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/MohamTahaB/massif-miner/internal/codec"
+	"github.com/MohamTahaB/massif-miner/internal/decompress"
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Define the flags shared by the commands reading logs
+type inputFlags struct {
+	maxBytes     int64
+	maxLineBytes int
+	workers      int
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	in := &inputFlags{}
+	fs.Int64Var(&in.maxBytes, "max-bytes", decompress.DefaultMaxBytes, "maximum size of a log once decompressed, no limit when not positive")
+	fs.IntVar(&in.maxLineBytes, "max-line", digger.DefaultMaxLineBytes, "maximum length of a line of a log")
+	fs.IntVar(&in.workers, "parallel", 0, "number of parsing workers, the log being parsed sequentially when not positive")
+	return in
+}
+
+// Reads and parses the log at the given path, stdin standing for an empty path or "-". The log may also be in the binary export format
+func (in *inputFlags) load(e *env, path string) (*outlog.OutLog, error) {
+	r := e.stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	decompressed, _, err := decompress.NewReader(r, in.maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer decompressed.Close()
+
+	// Logs encoded by the binary export format are decoded instead of parsed
+	br := bufio.NewReader(decompressed)
+	if header, _ := br.Peek(len(codec.Magic)); codec.IsEncoded(header) {
+		log, err := codec.Decode(br)
+		if err != nil {
+			return nil, err
+		}
+		return &log, nil
+	}
+
+	var log outlog.OutLog
+	if in.workers > 0 {
+		log, err = digger.ParseParallel(br, digger.ParallelOptions{Workers: in.workers, MaxLineBytes: in.maxLineBytes})
+	} else {
+		log, err = digger.ParseMaxLine(br, in.maxLineBytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// Returns the only positional argument of a command, the input path, empty for stdin
+func inputPath(fs *flag.FlagSet) (string, error) {
+	switch fs.NArg() {
+	case 0:
+		return "", nil
+	case 1:
+		return fs.Arg(0), nil
+	default:
+		return "", usageErrorf("expected a single input file, found %d arguments", fs.NArg())
+	}
+}
+
+// Returns the output of a command: the file at the given path, created or truncated, or stdout for an empty path or "-".
+// The returned closer should be called once written, its error telling whether the output is complete
+func output(e *env, path string) (io.Writer, func() error, error) {
+	if path == "" || path == "-" {
+		return e.stdout, func() error { return nil }, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
+
+// Returns the detailed snapshot with the given id, or when the id is negative, the peak: the one massif flagged, or else the detailed snapshot with the largest total
+func detailedSnapshot(log *outlog.OutLog, id int) (*snapshot.Snapshot, error) {
+	if id < 0 {
+		id = log.Peak.FlaggedID
+	}
+
+	var found *snapshot.Snapshot
+	for i := range log.Snapshots {
+		ss := &log.Snapshots[i]
+		if id >= 0 && ss.Id == id {
+			found = ss
+			break
+		}
+		if id < 0 && ss.HeapTree != nil && (found == nil || total(ss) > total(found)) {
+			found = ss
+		}
+	}
+
+	switch {
+	case found == nil && id >= 0:
+		return nil, fmt.Errorf("snapshot %d not found", id)
+	case found == nil:
+		return nil, fmt.Errorf("the log has no detailed snapshot")
+	case found.HeapTree == nil:
+		return nil, fmt.Errorf("snapshot %d has no heap tree", found.Id)
+	}
+	return found, nil
+}
+
+func total(ss *snapshot.Snapshot) int {
+	return ss.MemHeapB + ss.MemHeapExtraB + ss.MemStacksB
+}
@@ -0,0 +1,142 @@
+// Command massif-miner parses massif.out logs and analyzes them, or serves them to the Massif Web Visualizer.
+//
+// Usage:
+//
+//	massif-miner <command> [flags] [file]
+//
+// Logs are read from the file, or from stdin when it is omitted or "-", and may be compressed.
+// Exit codes are 0 on success, 1 when the command fails, and 2 on usage errors
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes of the commands
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// Define a subcommand, running with its own flag set
+type command struct {
+	name  string
+	usage string
+	run   func(env *env, args []string) error
+}
+
+// Define the environment the commands run in, so that they can be tested without a process
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"parse", "parse a log to JSON", runParse},
+		{"summary", "print the header, peak and snapshot count of a log", runSummary},
+		{"tree", "print the heap tree of a snapshot", runTree},
+		{"top", "print the functions allocating the most in a snapshot", runTop},
+		{"diff", "compare the functions of two snapshots, of one log or of two", runDiff},
+		{"export", "export a log to another format", runExport},
+		{"serve", "serve the HTTP API, with logs preloaded", runServe},
+	}
+}
+
+// Returned by the commands on invalid arguments, so that they exit with the usage code
+type usageError struct {
+	err error
+	// Whether the flag set already reported the error, along with the usage
+	reported bool
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func usageErrorf(format string, args ...any) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+// Runs the command named by the first argument, and returns the exit code
+func run(args []string, e *env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help" {
+		printUsage(e.stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(e, args[1:])
+		var usageErr usageError
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &usageErr):
+			if !usageErr.reported {
+				fmt.Fprintf(e.stderr, "massif-miner %s: %v\n", cmd.name, err)
+			}
+			return exitUsage
+		default:
+			fmt.Fprintf(e.stderr, "massif-miner %s: %v\n", cmd.name, err)
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(e.stderr, "massif-miner: unknown command %s\n", args[0])
+	printUsage(e.stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: massif-miner <command> [flags] [file]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run massif-miner <command> -h for the flags of a command.")
+}
+
+// Returns the flag set of a command, reporting its errors to the command instead of exiting
+func newFlagSet(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: massif-miner %s [flags] %s\n\nflags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Parses the flags of a command, flag errors being usage errors
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err: err, reported: true}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/index"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/overhead"
+	"github.com/MohamTahaB/massif-miner/internal/top"
+)
+
+const artifact = "../../internal/utils/artifacts/massif.out.log"
+
+// Runs the command line, with the given stdin, and returns the exit code and the outputs
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &env{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestParse_OK(t *testing.T) {
+
+	content, err := os.ReadFile(artifact)
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	// From stdin, and from a file with the parallel parsing, to an output file
+	output := filepath.Join(t.TempDir(), "massif.json")
+	for _, args := range [][]string{{"parse"}, {"parse", "-parallel", "2", "-o", output, artifact}} {
+		code, stdout, stderr := runCommand(t, string(content), args...)
+		if code != exitOK {
+			t.Fatalf("cli test error: %v exited with %d: %s", args, code, stderr)
+		}
+		if stdout == "" {
+			b, _ := os.ReadFile(output)
+			stdout = string(b)
+		}
+
+		var log outlog.OutLog
+		if err := json.Unmarshal([]byte(stdout), &log); err != nil {
+			t.Fatalf("cli test error: %v", err)
+		}
+		// CAUTION: change in the artifacts should be taken into account here as well
+		if len(log.Snapshots) != 60 || log.Peak.FlaggedID != 45 {
+			t.Fatalf("cli test error: unexpected log with %d snapshots and peak %+v", len(log.Snapshots), log.Peak)
+		}
+	}
+}
+
+func TestSummary_OK(t *testing.T) {
+
+	// CAUTION: the bzip2 artifact should be regenerated along with the massif.out log
+	code, stdout, stderr := runCommand(t, "", "summary", artifact+".bz2")
+	if code != exitOK {
+		t.Fatalf("cli test error: exited with %d: %s", code, stderr)
+	}
+
+	for _, expected := range []string{"./alloc_dealloc", "60 (8 detailed)", "snapshot 45", "168544 B", "consistent", "overhead spikes:  none", "tiny allocations: no", "stacks not profiled"} {
+		if !strings.Contains(stdout, expected) {
+			t.Fatalf("cli test error: %q not found in the summary:\n%s", expected, stdout)
+		}
+	}
+
+	code, stdout, stderr = runCommand(t, "", "summary", "-json", artifact)
+	if code != exitOK {
+		t.Fatalf("cli test error: exited with %d: %s", code, stderr)
+	}
+	var sum summary
+	if err := json.Unmarshal([]byte(stdout), &sum); err != nil || sum.OverheadSpikes == nil || len(sum.OverheadSpikes) != 0 || sum.TinyAllocations || sum.AvgBlockSize <= overhead.TinyBlockSize {
+		t.Fatalf("cli test error: unexpected JSON summary %s, %v", stdout, err)
+	}
+
+	// The chart follows the text summary, stacks not being profiled in the artifact
+	code, stdout, stderr = runCommand(t, "", "summary", "-chart", artifact)
+	if code != exitOK {
+		t.Fatalf("cli test error: exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "168544 B |") || !strings.Contains(stdout, "# heap  (stacks not profiled)") {
+		t.Fatalf("cli test error: chart not found in the summary:\n%s", stdout)
+	}
+
+	// Spikes and tiny allocations, as found in a log of small blocks
+	var out strings.Builder
+	sum.OverheadSpikes, sum.TinyAllocations, sum.AvgBlockSize = []int{12, 40}, true, 24
+	if err := writeSummary(&out, sum); err != nil || !strings.Contains(out.String(), "snapshots 12, 40") || !strings.Contains(out.String(), "tiny allocations: yes, live blocks of 24.0 B") {
+		t.Fatalf("cli test error: unexpected summary %s, %v", out.String(), err)
+	}
+}
+
+func TestTreeAndTop_OK(t *testing.T) {
+
+	code, stdout, stderr := runCommand(t, "", "tree", "-snapshot", "4", "-view", "callers", "-focus", "main", artifact)
+	if code != exitOK {
+		t.Fatalf("cli test error: exited with %d: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if !strings.HasPrefix(lines[1], "  ") || !strings.Contains(lines[1], "main") {
+		t.Fatalf("cli test error: unexpected callers tree:\n%s", stdout)
+	}
+
+	code, stdout, stderr = runCommand(t, "", "top", "-n", "2", "-json", artifact)
+	if code != exitOK {
+		t.Fatalf("cli test error: exited with %d: %s", code, stderr)
+	}
+	var sites []top.Site
+	if err := json.Unmarshal([]byte(stdout), &sites); err != nil || len(sites) != 2 || sites[0].Func != "allocateAndDeallocate()" {
+		t.Fatalf("cli test error: unexpected sites %+v, %v", sites, err)
+	}
+}
+
+func TestDiff_OK(t *testing.T) {
+
+	// The peak of a log against itself does not change
+	code, stdout, stderr := runCommand(t, "", "diff", "-json", artifact, artifact)
+	if code != exitOK || strings.TrimSpace(stdout) != "[]" {
+		t.Fatalf("cli test error: exited with %d and output %s: %s", code, stdout, stderr)
+	}
+
+	code, stdout, stderr = runCommand(t, "", "diff", "-from", "45", "-to", "58", artifact)
+	if code != exitOK || !strings.Contains(stdout, "-41350") {
+		t.Fatalf("cli test error: exited with %d and output %s: %s", code, stdout, stderr)
+	}
+}
+
+func TestExport_Binary_OK(t *testing.T) {
+
+	// A log exported to the binary format loads as the log itself
+	output := filepath.Join(t.TempDir(), "massif.bin")
+	if code, _, stderr := runCommand(t, "", "export", "-format", "binary", "-o", output, artifact); code != exitOK {
+		t.Fatalf("cli test error: export exited with %d: %s", code, stderr)
+	}
+
+	_, expected, _ := runCommand(t, "", "summary", artifact)
+	code, summary, stderr := runCommand(t, "", "summary", output)
+	if code != exitOK || summary != expected {
+		t.Fatalf("cli test error: summary of the binary log exited with %d: %s%s", code, summary, stderr)
+	}
+}
+
+func TestExport_Formats_OK(t *testing.T) {
+	for format := range exporters {
+		// The svg format needs the dot binary of Graphviz
+		if format == "svg" {
+			continue
+		}
+
+		code, stdout, stderr := runCommand(t, "", "export", "-format", format, artifact)
+		if code != exitOK || stdout == "" {
+			t.Fatalf("cli test error: export to %s exited with %d: %s", format, code, stderr)
+		}
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if code, _, stderr := runCommand(t, "", "export", "-format", "svg", artifact); code != exitError || !strings.Contains(stderr, "dot") {
+		t.Fatalf("cli test error: export to svg without dot exited with %d: %s", code, stderr)
+	}
+}
+
+func TestServe_Preload_OK(t *testing.T) {
+
+	// Plain logs are indexed, the sidecar index being saved next to them, out of the artifacts
+	content, err := os.ReadFile(artifact)
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+	plain := filepath.Join(t.TempDir(), "massif.out.log")
+	if err := os.WriteFile(plain, content, 0o644); err != nil {
+		t.Fatalf("cli test error: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	fs := newFlagSet(&env{stderr: &stderr}, "serve", "[file...]")
+	in := addInputFlags(fs)
+	if err := fs.Parse([]string{plain, artifact + ".bz2"}); err != nil {
+		t.Fatalf("cli test error: %v", err)
+	}
+
+	server, err := newServer(&env{stderr: &stderr}, in, fs)
+	if err != nil {
+		t.Fatalf("cli test error: %v", err)
+	}
+	defer server.Close()
+
+	for _, id := range []string{"1", "2"} {
+		if log, ok := server.Profile(id); !ok || len(log.Snapshots) != 60 {
+			t.Fatalf("cli test error: the log of profile %s is not preloaded", id)
+		}
+	}
+	if log, _ := server.Profile("1"); log.Snapshots[45].HeapTree != nil {
+		t.Fatal("cli test error: the plain log is not indexed")
+	}
+	if log, _ := server.Profile("2"); log.Snapshots[45].HeapTree == nil {
+		t.Fatal("cli test error: the compressed log is not loaded")
+	}
+	if _, err := os.Stat(index.SidecarPath(plain)); err != nil {
+		t.Fatalf("cli test error: sidecar index not saved: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "indexed as profile 1") || !strings.Contains(stderr.String(), "loaded as profile 2") {
+		t.Fatalf("cli test error: unexpected output %s", stderr.String())
+	}
+}
+
+func TestRun_KO(t *testing.T) {
+
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"bogus"}, exitUsage},
+		{[]string{"summary", "-bogus"}, exitUsage},
+		{[]string{"summary", artifact, artifact}, exitUsage},
+		{[]string{"tree", "-prune", "x", artifact}, exitUsage},
+		{[]string{"export", "-format", "bogus", artifact}, exitUsage},
+		{[]string{"diff", "-", "-"}, exitUsage},
+		{[]string{"summary", "missing.out"}, exitError},
+		{[]string{"tree", "-snapshot", "3", artifact}, exitError},
+		{[]string{"summary"}, exitError},
+	} {
+		if code, _, _ := runCommand(t, "not a massif log", tc.args...); code != tc.code {
+			t.Fatalf("cli test error: %v exited with %d, expected %d", tc.args, code, tc.code)
+		}
+	}
+
+	// Help is not an error
+	if code, _, _ := runCommand(t, "", "top", "-h"); code != exitOK {
+		t.Fatalf("cli test error: help exited with %d", code)
+	}
+}
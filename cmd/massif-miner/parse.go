@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/overhead"
+	"github.com/MohamTahaB/massif-miner/internal/stacks"
+	"github.com/MohamTahaB/massif-miner/internal/timeline"
+)
+
+func runParse(e *env, args []string) error {
+	fs := newFlagSet(e, "parse", "[file]")
+	in := addInputFlags(fs)
+	out := fs.String("o", "", "output file, stdout when empty or -")
+	indent := fs.Bool("indent", false, "indent the JSON output")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path, err := inputPath(fs)
+	if err != nil {
+		return err
+	}
+
+	log, err := in.load(e, path)
+	if err != nil {
+		return err
+	}
+
+	w, closeOutput, err := output(e, *out)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(w, log, *indent); err != nil {
+		closeOutput()
+		return err
+	}
+	return closeOutput()
+}
+
+// Define the summary of a log, as printed by the summary command
+type summary struct {
+	Cmd       string      `json:"cmd"`
+	Desc      string      `json:"desc"`
+	TimeUnit  string      `json:"timeUnit"`
+	Snapshots int         `json:"snapshots"`
+	Detailed  int         `json:"detailed"`
+	Peak      outlog.Peak `json:"peak"`
+	// Time of the peak, in the log time unit, and its breakdown
+	PeakTime          string  `json:"peakTime"`
+	PeakMemHeapB      int     `json:"peakMemHeapB"`
+	PeakMemHeapExtraB int     `json:"peakMemHeapExtraB"`
+	PeakMemStacksB    int     `json:"peakMemStacksB"`
+	OverheadRatio     float64 `json:"overheadRatio"`
+	PeakOverheadRatio float64 `json:"peakOverheadRatio"`
+	PeakOverheadID    int     `json:"peakOverheadSnapshotId"`
+	// Snapshots where the overhead spikes, and whether the run is dominated by tiny allocations, see overhead.Report
+	OverheadSpikes  []int   `json:"overheadSpikes"`
+	AvgBlockSize    float64 `json:"avgBlockSize"`
+	TinyAllocations bool    `json:"tinyAllocations"`
+	StacksProfiled  bool    `json:"stacksProfiled"`
+	PeakStacksB     int     `json:"peakStacksB"`
+	PeakStacksID    int     `json:"peakStacksSnapshotId"`
+}
+
+func summarize(log *outlog.OutLog) summary {
+	overheadReport := overhead.Analyze(log)
+	stacksReport := stacks.Analyze(log)
+
+	sum := summary{
+		Cmd:               log.Cmd,
+		Desc:              log.Desc,
+		TimeUnit:          log.TimeUnit.String(),
+		Snapshots:         len(log.Snapshots),
+		Peak:              log.Peak,
+		OverheadRatio:     overheadReport.Ratio,
+		PeakOverheadRatio: overheadReport.PeakRatio,
+		PeakOverheadID:    overheadReport.PeakRatioSnapshotID,
+		OverheadSpikes:    overheadReport.Spikes,
+		AvgBlockSize:      overheadReport.AvgBlockSize,
+		TinyAllocations:   overheadReport.TinyAllocations,
+		StacksProfiled:    stacksReport.Profiled,
+		PeakStacksB:       stacksReport.PeakStacksB,
+		PeakStacksID:      stacksReport.PeakSnapshotID,
+	}
+
+	for _, ss := range log.Snapshots {
+		if ss.HeapTree != nil {
+			sum.Detailed++
+		}
+		if ss.Id == log.Peak.MaxID {
+			sum.PeakTime = timeline.Time{Raw: ss.Time, Unit: log.TimeUnit}.String()
+			sum.PeakMemHeapB, sum.PeakMemHeapExtraB, sum.PeakMemStacksB = ss.MemHeapB, ss.MemHeapExtraB, ss.MemStacksB
+		}
+	}
+
+	return sum
+}
+
+func runSummary(e *env, args []string) error {
+	fs := newFlagSet(e, "summary", "[file]")
+	in := addInputFlags(fs)
+	asJSON := fs.Bool("json", false, "print the summary as JSON")
+	chart := fs.Bool("chart", false, "append a text chart of the heap versus stacks memory over the snapshots to the text summary")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path, err := inputPath(fs)
+	if err != nil {
+		return err
+	}
+
+	log, err := in.load(e, path)
+	if err != nil {
+		return err
+	}
+
+	sum := summarize(log)
+	if *asJSON {
+		return writeJSON(e.stdout, sum, true)
+	}
+	if err := writeSummary(e.stdout, sum); err != nil {
+		return err
+	}
+	if *chart {
+		fmt.Fprintln(e.stdout)
+		return stacks.WriteChart(e.stdout, stacks.Analyze(log), chartWidth, chartHeight)
+	}
+	return nil
+}
+
+// Size of the summary chart, in columns and rows
+const (
+	chartWidth  = 60
+	chartHeight = 8
+)
+
+func writeSummary(w io.Writer, sum summary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintf(tw, "command:\t%s\n", sum.Cmd)
+	fmt.Fprintf(tw, "description:\t%s\n", sum.Desc)
+	fmt.Fprintf(tw, "time unit:\t%s\n", sum.TimeUnit)
+	fmt.Fprintf(tw, "snapshots:\t%d (%d detailed)\n", sum.Snapshots, sum.Detailed)
+
+	if sum.Peak.MaxID == -1 {
+		fmt.Fprintf(tw, "peak:\tnone\n")
+	} else {
+		fmt.Fprintf(tw, "peak:\tsnapshot %d at %s, %d B (heap %d B, extra %d B, stacks %d B)\n",
+			sum.Peak.MaxID, sum.PeakTime, sum.Peak.MaxTotal, sum.PeakMemHeapB, sum.PeakMemHeapExtraB, sum.PeakMemStacksB)
+
+		switch {
+		case sum.Peak.FlaggedID == -1:
+			fmt.Fprintf(tw, "peak check:\tno peak flagged by massif\n")
+		case sum.Peak.Consistent:
+			fmt.Fprintf(tw, "peak check:\tconsistent with massif, true peak within [%d, %d] B\n", sum.Peak.BandLow, sum.Peak.BandHigh)
+		default:
+			fmt.Fprintf(tw, "peak check:\tmassif flagged snapshot %d, %d B\n", sum.Peak.FlaggedID, sum.Peak.FlaggedTotal)
+		}
+
+		fmt.Fprintf(tw, "overhead:\t%.2f%% extra heap over the run, highest %.2f%% at snapshot %d\n", 100*sum.OverheadRatio, 100*sum.PeakOverheadRatio, sum.PeakOverheadID)
+		fmt.Fprintf(tw, "overhead spikes:\t%s\n", formatSpikes(sum.OverheadSpikes))
+		if sum.TinyAllocations {
+			fmt.Fprintf(tw, "tiny allocations:\tyes, live blocks of %.1f B on average, the allocator overhead dominates\n", sum.AvgBlockSize)
+		} else {
+			fmt.Fprintf(tw, "tiny allocations:\tno, live blocks of %.1f B on average\n", sum.AvgBlockSize)
+		}
+	}
+	stacksReport := stacks.Report{Profiled: sum.StacksProfiled, PeakSnapshotID: sum.PeakStacksID, PeakStacksB: sum.PeakStacksB}
+	fmt.Fprintf(tw, "stacks peak:\t%s\n", stacksReport.PeakSummary())
+
+	return tw.Flush()
+}
+
+// Formats the snapshot ids of the overhead spikes, e.g. "snapshots 12, 40"
+func formatSpikes(spikes []int) string {
+	if len(spikes) == 0 {
+		return "none"
+	}
+	ids := make([]string, len(spikes))
+	for i, id := range spikes {
+		ids[i] = strconv.Itoa(id)
+	}
+	if len(ids) == 1 {
+		return "snapshot " + ids[0]
+	}
+	return "snapshots " + strings.Join(ids, ", ")
+}
+
+func writeJSON(w io.Writer, v any, indent bool) error {
+	encoder := json.NewEncoder(w)
+	if indent {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(v)
+}
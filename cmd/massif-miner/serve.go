@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MohamTahaB/massif-miner/internal/api"
+	"github.com/MohamTahaB/massif-miner/internal/codec"
+	"github.com/MohamTahaB/massif-miner/internal/decompress"
+)
+
+func runServe(e *env, args []string) error {
+	fs := newFlagSet(e, "serve", "[file...]")
+	in := addInputFlags(fs)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	server, err := newServer(e, in, fs)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	gin.SetMode(gin.ReleaseMode)
+	fmt.Fprintf(e.stderr, "massif-miner serve: listening on %s\n", *addr)
+	return http.ListenAndServe(*addr, server.Router())
+}
+
+// Returns a server holding the logs given as arguments, uploads being limited like the logs read from files.
+// Plain massif files are served through their sidecar index, built next to them if needed, so that their heap trees are not held in memory.
+// Compressed logs and logs in the binary export format are loaded whole
+func newServer(e *env, in *inputFlags, fs *flag.FlagSet) (*api.Server, error) {
+	server := api.NewServer()
+	server.MaxUploadBytes = in.maxBytes
+	if server.MaxUploadBytes <= 0 {
+		server.MaxUploadBytes = decompress.DefaultMaxBytes
+	}
+
+	for _, path := range fs.Args() {
+		id, how, err := addProfile(e, in, server, path)
+		if err != nil {
+			server.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(e.stderr, "massif-miner serve: %s %s as profile %s\n", path, how, id)
+	}
+
+	return server, nil
+}
+
+// Adds the log at the given path to the server, and returns its id and how it is served: "indexed" or "loaded"
+func addProfile(e *env, in *inputFlags, server *api.Server, path string) (string, string, error) {
+	// Stdin cannot be seeked into
+	indexed := false
+	if path != "" && path != "-" {
+		var err error
+		if indexed, err = indexable(path); err != nil {
+			return "", "", err
+		}
+	}
+	if indexed {
+		id, err := server.AddIndexedProfile(path)
+		return id, "indexed", err
+	}
+
+	log, err := in.load(e, path)
+	if err != nil {
+		return "", "", err
+	}
+	return server.AddProfile(log), "loaded", nil
+}
+
+// Returns whether the file at the given path is a plain massif file, which can be indexed
+func indexable(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, max(len(codec.Magic), 6))
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	header = header[:n]
+	return decompress.Sniff(header) == decompress.PLAIN && !codec.IsEncoded(header), nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/top"
+)
+
+func runTree(e *env, args []string) error {
+	fs := newFlagSet(e, "tree", "[file]")
+	in := addInputFlags(fs)
+	id := fs.Int("snapshot", -1, "id of the detailed snapshot, the peak when negative")
+	focus := fs.String("focus", "", "keep only the paths going through a frame matching the regex")
+	ignore := fs.String("ignore", "", "drop the paths going through a frame matching the regex")
+	hide := fs.String("hide", "", "elide the frames matching the regex")
+	prune := fs.String("prune", "", "prune the nodes below a number of bytes, e.g. 1024, or a share of the tree, e.g. 1.5%")
+	view := fs.String("view", "allocators", "tree view: allocators, or callers for the inverted tree")
+	asJSON := fs.Bool("json", false, "print the tree as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path, err := inputPath(fs)
+	if err != nil {
+		return err
+	}
+
+	filter, err := heaptree.ParseFilter(*focus, *ignore, *hide, *prune)
+	if err != nil {
+		return usageError{err: err}
+	}
+	if *view != "allocators" && *view != "callers" {
+		return usageErrorf("unknown view %s", *view)
+	}
+
+	log, err := in.load(e, path)
+	if err != nil {
+		return err
+	}
+	ss, err := detailedSnapshot(log, *id)
+	if err != nil {
+		return err
+	}
+
+	tree := ss.HeapTree
+	if *view == "callers" {
+		tree = heaptree.Invert(tree)
+	}
+	tree = filter.Apply(tree)
+
+	if *asJSON {
+		return writeJSON(e.stdout, tree, true)
+	}
+	return writeTree(e.stdout, tree)
+}
+
+// Writes the heap tree as indented text, a node per line with its bytes and its share of the tree
+func writeTree(w io.Writer, tree *heaptree.HeapTree) error {
+	var err error
+	tree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+		if err != nil {
+			return
+		}
+
+		percent := 0.0
+		if tree.Memory > 0 {
+			percent = 100 * float64(node.Memory) / float64(tree.Memory)
+		}
+
+		frame := node.Func
+		if len(ancestors) > 0 {
+			frame = node.FrameName()
+			switch {
+			case node.FuncFullDesc != "":
+				frame += " (in " + node.FuncFullDesc + ")"
+			case node.Location != "":
+				frame += " (" + node.Location + ")"
+			}
+		}
+		_, err = fmt.Fprintf(w, "%s%d B (%.2f%%) %s\n", strings.Repeat("  ", len(ancestors)), node.Memory, percent, frame)
+	})
+	return err
+}
+
+func runTop(e *env, args []string) error {
+	fs := newFlagSet(e, "top", "[file]")
+	in := addInputFlags(fs)
+	id := fs.Int("snapshot", -1, "id of the detailed snapshot, the peak when negative")
+	n := fs.Int("n", 10, "number of functions, all of them when not positive")
+	asJSON := fs.Bool("json", false, "print the functions as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path, err := inputPath(fs)
+	if err != nil {
+		return err
+	}
+
+	log, err := in.load(e, path)
+	if err != nil {
+		return err
+	}
+	ss, err := detailedSnapshot(log, *id)
+	if err != nil {
+		return err
+	}
+
+	sites := top.TopN(ss, *n)
+	if *asJSON {
+		return top.WriteJSON(e.stdout, sites)
+	}
+	return top.WriteTable(e.stdout, sites)
+}
+
+func runDiff(e *env, args []string) error {
+	fs := newFlagSet(e, "diff", "file [file]")
+	in := addInputFlags(fs)
+	from := fs.Int("from", -1, "id of the snapshot to compare from, the first detailed one of a single log, or the peak of the first of two logs, when negative")
+	to := fs.Int("to", -1, "id of the snapshot to compare to, the last detailed one of a single log, or the peak of the second of two logs, when negative")
+	n := fs.Int("n", 10, "number of functions, all of them when not positive")
+	asJSON := fs.Bool("json", false, "print the deltas as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		return usageErrorf("expected one or two input files, found %d arguments", fs.NArg())
+	}
+	if fs.NArg() == 2 && fs.Arg(0) == fs.Arg(1) && (fs.Arg(0) == "-" || fs.Arg(0) == "") {
+		return usageErrorf("stdin can only be read once")
+	}
+
+	before, err := in.load(e, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after := before
+	if fs.NArg() == 2 {
+		if after, err = in.load(e, fs.Arg(1)); err != nil {
+			return err
+		}
+	} else {
+		// Compare the first detailed snapshot to the last one by default
+		if *from < 0 {
+			*from = detailedID(before, true)
+		}
+		if *to < 0 {
+			*to = detailedID(before, false)
+		}
+	}
+
+	fromSnapshot, err := detailedSnapshot(before, *from)
+	if err != nil {
+		return err
+	}
+	toSnapshot, err := detailedSnapshot(after, *to)
+	if err != nil {
+		return err
+	}
+
+	deltas := top.Diff(fromSnapshot, toSnapshot)
+	if *n > 0 && *n < len(deltas) {
+		deltas = deltas[:*n]
+	}
+	if *asJSON {
+		return top.WriteDiffJSON(e.stdout, deltas)
+	}
+	return top.WriteDiffTable(e.stdout, deltas)
+}
+
+// Returns the id of the first or last detailed snapshot of the log, -1 when there is none
+func detailedID(log *outlog.OutLog, first bool) int {
+	id := -1
+	for _, ss := range log.Snapshots {
+		if ss.HeapTree == nil {
+			continue
+		}
+		id = ss.Id
+		if first {
+			break
+		}
+	}
+	return id
+}
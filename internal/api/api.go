@@ -0,0 +1,321 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MohamTahaB/massif-miner/internal/decompress"
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/index"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/overhead"
+	"github.com/MohamTahaB/massif-miner/internal/resample"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+	"github.com/MohamTahaB/massif-miner/internal/stacks"
+	"github.com/MohamTahaB/massif-miner/internal/timeline"
+)
+
+// Define the HTTP API of the Massif Web Visualizer backend, holding the uploaded profiles in memory
+type Server struct {
+	// Maximum size of an uploaded profile once decompressed, no limit being applied when not positive
+	MaxUploadBytes int64
+
+	mu       sync.RWMutex
+	profiles map[string]*profile
+	nextID   int
+}
+
+// Define a profile of the server: a log parsed in memory, or a massif file read through its index
+type profile struct {
+	// Whole log of the profile, or its snapshot headers only for an indexed profile
+	log *outlog.OutLog
+	// Reader of the heap trees of an indexed profile, nil for the others
+	reader *index.Reader
+	closer io.Closer
+}
+
+// Initiates a server with no profiles
+func NewServer() *Server {
+	return &Server{
+		MaxUploadBytes: decompress.DefaultMaxBytes,
+		profiles:       make(map[string]*profile),
+	}
+}
+
+// Returns the gin engine serving the API routes
+func (s *Server) Router() *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	router.GET("/schema", getSchema)
+	router.POST("/profiles", s.uploadProfile)
+	router.GET("/profiles/:id", s.getProfile)
+	router.GET("/profiles/:id/timeline", s.getTimeline)
+	router.GET("/profiles/:id/overhead", s.getOverhead)
+	router.GET("/profiles/:id/stacks", s.getStacks)
+	router.GET("/profiles/:id/snapshots/:snapshot", s.getSnapshot)
+	router.GET("/profiles/:id/snapshots/:snapshot/tree", s.getTree)
+
+	return router
+}
+
+// Adds a parsed profile to the server, and returns its id
+func (s *Server) AddProfile(log *outlog.OutLog) string {
+	return s.add(&profile{log: log})
+}
+
+// Adds the massif file at the given path to the server, and returns its id. The file is read through its index, loaded from its sidecar or built if needed:
+// only the snapshot headers are held in memory, heap trees being read from the file on demand. The file is kept open until the server is closed
+func (s *Server) AddIndexedProfile(path string) (string, error) {
+	rd, closer, err := index.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	log := rd.Index().Log()
+	return s.add(&profile{log: &log, reader: rd, closer: closer}), nil
+}
+
+func (s *Server) add(p *profile) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.profiles[id] = p
+	return id
+}
+
+// Returns the log of the profile with the given id, if any. The log of an indexed profile only holds the snapshot headers
+func (s *Server) Profile(id string) (*outlog.OutLog, bool) {
+	p, ok := s.profile(id)
+	if !ok {
+		return nil, false
+	}
+	return p.log, true
+}
+
+func (s *Server) profile(id string) (*profile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.profiles[id]
+	return p, ok
+}
+
+// Closes the files of the indexed profiles
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, p := range s.profiles {
+		if p.closer != nil {
+			errs = append(errs, p.closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Parses a massif log, sent either as the "file" field of a multipart form or as the raw request body.
+// Compressed logs are decompressed on the fly, up to the upload size limit
+func (s *Server) uploadProfile(c *gin.Context) {
+	var r io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, fmt.Errorf("upload error: %v", err))
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	decompressed, _, err := decompress.NewReader(r, s.MaxUploadBytes)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+	defer decompressed.Close()
+
+	log, err := digger.Parse(decompressed)
+	if errors.Is(err, decompress.ErrTooLarge) {
+		abortWithError(c, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	id := s.AddProfile(&log)
+	c.JSON(http.StatusCreated, gin.H{
+		"id":        id,
+		"snapshots": len(log.Snapshots),
+	})
+}
+
+// Returns the JSON Schema of the profiles, as returned by the profile route
+func getSchema(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", outlog.Schema)
+}
+
+// Returns a profile. Indexed profiles are returned without their heap trees, to be fetched snapshot by snapshot
+func (s *Server) getProfile(c *gin.Context) {
+	p, ok := s.profileParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, p.log)
+}
+
+// Returns the timeline of a profile. The "points" query parameter downsamples it to about that many points, with the "method" query parameter algorithm
+func (s *Server) getTimeline(c *gin.Context) {
+	p, ok := s.profileParam(c)
+	if !ok {
+		return
+	}
+
+	points, err := strconv.Atoi(c.DefaultQuery("points", "0"))
+	if err != nil || points < 0 {
+		abortWithError(c, http.StatusBadRequest, fmt.Errorf("invalid number of points %s", c.Query("points")))
+		return
+	}
+	method, err := resample.ParseMethod(c.Query("method"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	resampled := *p.log
+	resampled.Snapshots = resample.Resample(p.chartSnapshots(), points, method)
+	c.JSON(http.StatusOK, timeline.Build(&resampled))
+}
+
+func (s *Server) getOverhead(c *gin.Context) {
+	p, ok := s.profileParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, overhead.Analyze(p.log))
+}
+
+func (s *Server) getStacks(c *gin.Context) {
+	p, ok := s.profileParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, stacks.Analyze(p.log))
+}
+
+// Returns a snapshot of a profile, heap tree included
+func (s *Server) getSnapshot(c *gin.Context) {
+	ss, ok := s.snapshotParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, ss)
+}
+
+// Returns the heap tree of a detailed snapshot, filtered with the focus, ignore, hide and prune query parameters.
+// The "view" query parameter set to "callers" returns the inverted tree
+func (s *Server) getTree(c *gin.Context) {
+	ss, ok := s.snapshotParam(c)
+	if !ok {
+		return
+	}
+	if ss.HeapTree == nil {
+		abortWithError(c, http.StatusNotFound, fmt.Errorf("snapshot %d has no heap tree", ss.Id))
+		return
+	}
+
+	filter, err := heaptree.ParseFilter(c.Query("focus"), c.Query("ignore"), c.Query("hide"), c.Query("prune"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	tree := ss.HeapTree
+	switch view := c.DefaultQuery("view", "allocators"); view {
+	case "allocators":
+	case "callers":
+		tree = heaptree.Invert(tree)
+	default:
+		abortWithError(c, http.StatusBadRequest, fmt.Errorf("unknown view %s", view))
+		return
+	}
+
+	c.JSON(http.StatusOK, filter.Apply(tree))
+}
+
+// Returns the snapshots of the profile to chart. The detailed snapshots of an indexed profile are given an empty heap tree,
+// so that resampling keeps them and the timeline flags them, without reading their actual heap tree
+func (p *profile) chartSnapshots() []snapshot.Snapshot {
+	if p.reader == nil {
+		return p.log.Snapshots
+	}
+
+	snapshots := slices.Clone(p.log.Snapshots)
+	for i := range snapshots {
+		if entry, ok := p.reader.Index().Entry(snapshots[i].Id); ok && entry.Detailed() {
+			snapshots[i].HeapTree = &heaptree.HeapTree{}
+		}
+	}
+	return snapshots
+}
+
+// Returns the profile matching the id path parameter, aborting the request if not found
+func (s *Server) profileParam(c *gin.Context) (*profile, bool) {
+	p, ok := s.profile(c.Param("id"))
+	if !ok {
+		abortWithError(c, http.StatusNotFound, fmt.Errorf("profile %s not found", c.Param("id")))
+	}
+	return p, ok
+}
+
+// Returns the snapshot matching the id and snapshot path parameters, aborting the request if not found.
+// The snapshots of an indexed profile are read from its file
+func (s *Server) snapshotParam(c *gin.Context) (*snapshot.Snapshot, bool) {
+	p, ok := s.profileParam(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.Atoi(c.Param("snapshot"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, fmt.Errorf("invalid snapshot id %s", c.Param("snapshot")))
+		return nil, false
+	}
+
+	if p.reader != nil {
+		if _, found := p.reader.Index().Entry(id); found {
+			ss, err := p.reader.Snapshot(id)
+			if err != nil {
+				abortWithError(c, http.StatusInternalServerError, err)
+				return nil, false
+			}
+			return &ss, true
+		}
+	} else {
+		for i := range p.log.Snapshots {
+			if p.log.Snapshots[i].Id == id {
+				return &p.log.Snapshots[i], true
+			}
+		}
+	}
+
+	abortWithError(c, http.StatusNotFound, fmt.Errorf("snapshot %d not found", id))
+	return nil, false
+}
+
+func abortWithError(c *gin.Context, status int, err error) {
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
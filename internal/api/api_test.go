@@ -0,0 +1,374 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/index"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/overhead"
+	"github.com/MohamTahaB/massif-miner/internal/stacks"
+	"github.com/MohamTahaB/massif-miner/internal/timeline"
+)
+
+// Uploads the massif.out log in the artifacts to the server, and returns the profile id
+func uploadArtifact(t *testing.T, router http.Handler) string {
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/profiles", bytes.NewReader(content)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("api test error: upload failed with status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		ID        string `json:"id"`
+		Snapshots int    `json:"snapshots"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if resp.Snapshots != 60 {
+		t.Fatalf("api test error: expected 60 snapshots, found %d", resp.Snapshots)
+	}
+
+	return resp.ID
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestUploadProfile_Multipart_OK(t *testing.T) {
+	router := NewServer().Router()
+
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "massif.out.log")
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/profiles", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("api test error: upload failed with status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUploadProfile_KO(t *testing.T) {
+	router := NewServer().Router()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/profiles", bytes.NewReader([]byte("not a massif log"))))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("api test error: expected status 400, found %d", rec.Code)
+	}
+}
+
+func TestUploadProfile_Compressed_OK(t *testing.T) {
+	router := NewServer().Router()
+
+	// CAUTION: the bzip2 artifact should be regenerated along with the massif.out log
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log.bz2")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/profiles", bytes.NewReader(content)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("api test error: upload failed with status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUploadProfile_TooLarge_KO(t *testing.T) {
+	server := NewServer()
+	server.MaxUploadBytes = 1 << 10
+	router := server.Router()
+
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log.bz2")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/profiles", bytes.NewReader(content)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("api test error: expected status 413, found %d", rec.Code)
+	}
+}
+
+func TestGetProfile_OK(t *testing.T) {
+	router := NewServer().Router()
+	id := uploadArtifact(t, router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("api test error: unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	log, err := outlog.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if len(log.Snapshots) != 60 || log.Peak.MaxID != 45 || log.Snapshots[45].HeapTree == nil {
+		t.Fatalf("api test error: unexpected profile %+v", log.Peak)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/schema", nil))
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), outlog.Schema) {
+		t.Fatalf("api test error: unexpected schema response %d", rec.Code)
+	}
+}
+
+func TestGetTree_Filters_OK(t *testing.T) {
+	router := NewServer().Router()
+	id := uploadArtifact(t, router)
+
+	// Ignore the startup allocations of the peak snapshot
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id+"/snapshots/45/tree?ignore=_dl_init", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("api test error: unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var tree heaptree.HeapTree
+	if err := json.Unmarshal(rec.Body.Bytes(), &tree); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if tree.Memory != 165527-72704 || len(tree.HeapAllocationLeafs) != 2 {
+		t.Fatalf("api test error: unexpected filtered tree, memory %d and %d leafs", tree.Memory, len(tree.HeapAllocationLeafs))
+	}
+
+	// Callers view
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id+"/snapshots/45/tree?view=callers&prune=1%25", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &tree); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if rec.Code != http.StatusOK || tree.HeapAllocationLeafs[0].Func != "main" {
+		t.Fatalf("api test error: unexpected callers tree: %s", rec.Body.String())
+	}
+}
+
+func TestGetTree_KO(t *testing.T) {
+	router := NewServer().Router()
+	id := uploadArtifact(t, router)
+
+	for path, status := range map[string]int{
+		"/profiles/404/snapshots/45/tree":               http.StatusNotFound,
+		"/profiles/" + id + "/snapshots/x/tree":         http.StatusBadRequest,
+		"/profiles/" + id + "/snapshots/1000/tree":      http.StatusNotFound,
+		"/profiles/" + id + "/snapshots/46/tree":        http.StatusNotFound,
+		"/profiles/" + id + "/snapshots/45/tree?hide=(": http.StatusBadRequest,
+		"/profiles/" + id + "/snapshots/45/tree?view=x": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != status {
+			t.Fatalf("api test error: expected status %d for %s, found %d", status, path, rec.Code)
+		}
+	}
+}
+
+func TestGetTimeline_OK(t *testing.T) {
+	router := NewServer().Router()
+	id := uploadArtifact(t, router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id+"/timeline", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("api test error: unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var tl timeline.Timeline
+	if err := json.Unmarshal(rec.Body.Bytes(), &tl); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if len(tl.Points) != 60 || tl.Points[59].Position != 100 {
+		t.Fatalf("api test error: unexpected timeline with %d points", len(tl.Points))
+	}
+
+	// Downsampled timeline
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id+"/timeline?points=20&method=minmax", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &tl); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(tl.Points) > 20 || tl.Points[len(tl.Points)-1].Position != 100 {
+		t.Fatalf("api test error: unexpected downsampled timeline with %d points", len(tl.Points))
+	}
+
+	for _, query := range []string{"?points=-1", "?points=x", "?points=10&method=x"} {
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id+"/timeline"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("api test error: expected status 400 for %s, found %d", query, rec.Code)
+		}
+	}
+}
+
+func TestGetOverhead_OK(t *testing.T) {
+	router := NewServer().Router()
+	id := uploadArtifact(t, router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id+"/overhead", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("api test error: unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report overhead.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if len(report.Snapshots) != 60 || report.PeakRatioSnapshotID != 41 {
+		t.Fatalf("api test error: unexpected overhead report with %d snapshots", len(report.Snapshots))
+	}
+}
+
+func TestGetStacks_NotProfiled_OK(t *testing.T) {
+	router := NewServer().Router()
+	id := uploadArtifact(t, router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profiles/"+id+"/stacks", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("api test error: unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// CAUTION: the artifacts were recorded without --stacks=yes
+	var report stacks.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if report.Profiled || len(report.Breakdowns) != 60 || report.PeakSnapshotID != -1 {
+		t.Fatalf("api test error: unexpected stacks report with %d breakdowns", len(report.Breakdowns))
+	}
+}
+
+// Returns the body of a GET request on the router, failing on an unexpected status
+func get(t *testing.T, router http.Handler, path string) []byte {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("api test error: unexpected status %d for %s: %s", rec.Code, path, rec.Body.String())
+	}
+	return rec.Body.Bytes()
+}
+
+// Returns the JSON of a heap tree or a snapshot, with its frame ids cleared as they depend on the frames read so far
+func withoutFrameIDs(t *testing.T, body []byte) string {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	var clear func(any)
+	clear = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			delete(v, "frameId")
+			for _, child := range v {
+				clear(child)
+			}
+		case []any:
+			for _, child := range v {
+				clear(child)
+			}
+		}
+	}
+	clear(v)
+	cleared, _ := json.Marshal(v)
+	return string(cleared)
+}
+
+func TestAddIndexedProfile_OK(t *testing.T) {
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+	// The sidecar index is built next to the file, out of the artifacts
+	path := filepath.Join(t.TempDir(), "massif.out.log")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+
+	server := NewServer()
+	defer server.Close()
+	router := server.Router()
+	uploaded := uploadArtifact(t, router)
+	indexed, err := server.AddIndexedProfile(path)
+	if err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	if _, err := os.Stat(index.SidecarPath(path)); err != nil {
+		t.Fatalf("api test error: sidecar index not saved: %v", err)
+	}
+
+	// Indexed profiles hold no heap tree in memory
+	log, _ := server.Profile(indexed)
+	for _, ss := range log.Snapshots {
+		if ss.HeapTree != nil {
+			t.Fatalf("api test error: snapshot %d of the indexed profile holds its heap tree", ss.Id)
+		}
+	}
+
+	// Both profiles are served alike
+	for _, route := range []string{"/timeline", "/timeline?points=20", "/overhead", "/stacks"} {
+		if expected, found := get(t, router, "/profiles/"+uploaded+route), get(t, router, "/profiles/"+indexed+route); !bytes.Equal(expected, found) {
+			t.Fatalf("api test error: unexpected %s of the indexed profile: %s", route, found)
+		}
+	}
+	for _, route := range []string{"/snapshots/45", "/snapshots/3", "/snapshots/45/tree?ignore=_dl_init", "/snapshots/45/tree?view=callers", "/snapshots/58/tree"} {
+		expected, found := get(t, router, "/profiles/"+uploaded+route), get(t, router, "/profiles/"+indexed+route)
+		if withoutFrameIDs(t, expected) != withoutFrameIDs(t, found) {
+			t.Fatalf("api test error: unexpected %s of the indexed profile: %s", route, found)
+		}
+	}
+
+	profile, err := outlog.Decode(bytes.NewReader(get(t, router, "/profiles/"+indexed)))
+	if err != nil {
+		t.Fatalf("api test error: %v", err)
+	}
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if len(profile.Snapshots) != 60 || profile.Peak.MaxID != 45 || !profile.Peak.Consistent {
+		t.Fatalf("api test error: unexpected indexed profile %+v", profile.Peak)
+	}
+
+	for path, status := range map[string]int{
+		"/profiles/" + indexed + "/snapshots/1000":      http.StatusNotFound,
+		"/profiles/" + indexed + "/snapshots/46/tree":   http.StatusNotFound,
+		"/profiles/" + indexed + "/snapshots/x/tree":    http.StatusBadRequest,
+		"/profiles/" + indexed + "/snapshots/1000/tree": http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != status {
+			t.Fatalf("api test error: expected status %d for %s, found %d", status, path, rec.Code)
+		}
+	}
+}
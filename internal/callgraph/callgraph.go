@@ -0,0 +1,120 @@
+package callgraph
+
+import (
+	"sort"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+	"github.com/MohamTahaB/massif-miner/internal/top"
+)
+
+// Limits of the call graph when not specified, as in pprof
+const (
+	DefaultNodeCount    = 80
+	DefaultEdgeFraction = 0.001
+)
+
+// Define the limits of a call graph
+type Options struct {
+	// Maximum number of functions, the ones with the most inclusive bytes being kept. No limit is applied when not positive
+	NodeCount int
+	// Edges carrying less than this fraction of the heap are dropped
+	EdgeFraction float64
+}
+
+// Returns the options pprof uses by default
+func DefaultOptions() Options {
+	return Options{NodeCount: DefaultNodeCount, EdgeFraction: DefaultEdgeFraction}
+}
+
+// Define an edge of a call graph, the caller calling the callee on the way to the allocation functions
+type Edge struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	// Bytes allocated through the call
+	Bytes int `json:"bytes"`
+}
+
+// Define the call graph of a detailed snapshot, with a node per function, as folded by top.Sites
+type Graph struct {
+	SnapshotID int `json:"snapshotId"`
+	// Bytes of the snapshot heap, the percentages being relative to them
+	Total int        `json:"total"`
+	Nodes []top.Site `json:"nodes"`
+	Edges []Edge     `json:"edges"`
+	// Number of functions and edges dropped by the limits
+	DroppedNodes int `json:"droppedNodes"`
+	DroppedEdges int `json:"droppedEdges"`
+}
+
+// Builds the call graph of a detailed snapshot, within the limits of the options. The nodes are sorted by decreasing inclusive bytes, and the edges by decreasing bytes.
+// Returns an empty graph if the snapshot has no heap tree
+func Build(ss *snapshot.Snapshot, opts Options) Graph {
+	graph := Graph{SnapshotID: ss.Id}
+	if ss.HeapTree == nil {
+		return graph
+	}
+
+	graph.Total = ss.MemHeapB
+	if graph.Total == 0 {
+		graph.Total = ss.HeapTree.Memory
+	}
+
+	graph.Nodes = top.Sites(ss)
+	if opts.NodeCount > 0 && opts.NodeCount < len(graph.Nodes) {
+		graph.DroppedNodes = len(graph.Nodes) - opts.NodeCount
+		graph.Nodes = graph.Nodes[:opts.NodeCount]
+	}
+	kept := make(map[string]bool, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		kept[node.Func] = true
+	}
+
+	type call struct{ caller, callee string }
+	index := make(map[call]int)
+	var edges []Edge
+
+	ss.HeapTree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+		// The root stands for the allocation functions, which are not a node of the graph
+		if len(ancestors) < 2 {
+			return
+		}
+
+		c := call{caller: top.Label(node), callee: top.Label(ancestors[len(ancestors)-1])}
+
+		// Recursive calls are only accounted for once per path, at the occurrence closest to the allocation function
+		for i := 1; i < len(ancestors)-1; i++ {
+			if top.Label(ancestors[i+1]) == c.caller && top.Label(ancestors[i]) == c.callee {
+				return
+			}
+		}
+
+		i, ok := index[c]
+		if !ok {
+			i = len(edges)
+			index[c] = i
+			edges = append(edges, Edge{Caller: c.caller, Callee: c.callee})
+		}
+		edges[i].Bytes += node.Memory
+	})
+
+	for _, edge := range edges {
+		if !kept[edge.Caller] || !kept[edge.Callee] || float64(edge.Bytes) < opts.EdgeFraction*float64(graph.Total) {
+			graph.DroppedEdges++
+			continue
+		}
+		graph.Edges = append(graph.Edges, edge)
+	}
+
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Bytes != graph.Edges[j].Bytes {
+			return graph.Edges[i].Bytes > graph.Edges[j].Bytes
+		}
+		if graph.Edges[i].Caller != graph.Edges[j].Caller {
+			return graph.Edges[i].Caller < graph.Edges[j].Caller
+		}
+		return graph.Edges[i].Callee < graph.Edges[j].Callee
+	})
+
+	return graph
+}
@@ -0,0 +1,142 @@
+package callgraph
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+func peakSnapshot(t *testing.T) *snapshot.Snapshot {
+	// Open the massif.out log in the artifacts
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("callgraph test error: %v", err)
+	}
+	return &log.Snapshots[log.Peak.FlaggedID]
+}
+
+func TestBuild_OK(t *testing.T) {
+	ss := peakSnapshot(t)
+
+	graph := Build(ss, Options{})
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if graph.SnapshotID != 45 || graph.Total != 165527 || len(graph.Nodes) != 12 || graph.DroppedNodes != 0 || graph.DroppedEdges != 0 {
+		t.Fatalf("callgraph test error: unexpected graph with %d nodes, %d and %d dropped", len(graph.Nodes), graph.DroppedNodes, graph.DroppedEdges)
+	}
+	if edge := graph.Edges[0]; edge.Caller != "main" || edge.Callee != "allocateAndDeallocate()" || edge.Bytes != 92823 {
+		t.Fatalf("callgraph test error: unexpected heaviest edge %+v", edge)
+	}
+
+	// Edges to dropped nodes, and edges under the fraction, are dropped
+	limited := Build(ss, Options{NodeCount: 6, EdgeFraction: 0.5})
+	if len(limited.Nodes) != 6 || limited.DroppedNodes != 6 || len(limited.Edges) != 1 || limited.DroppedEdges != len(graph.Edges)-1 {
+		t.Fatalf("callgraph test error: unexpected limited graph %+v", limited)
+	}
+
+	if empty := Build(&snapshot.Snapshot{Id: 3}, DefaultOptions()); empty.SnapshotID != 3 || empty.Nodes != nil || empty.Edges != nil {
+		t.Fatalf("callgraph test error: unexpected graph of an empty snapshot %+v", empty)
+	}
+}
+
+func TestBuild_Recursion_OK(t *testing.T) {
+
+	// g calls f, which calls g, which calls f, which allocates
+	node := func(fn string, leafs ...*heaptree.HeapTree) *heaptree.HeapTree {
+		return &heaptree.HeapTree{Memory: 100, Frame: &heaptree.Frame{Address: "0x1", Func: fn}, HeapAllocationLeafs: leafs}
+	}
+	tree := node("heap allocation functions", node("f", node("g", node("f", node("g")))))
+	tree.Address = "root"
+
+	graph := Build(&snapshot.Snapshot{HeapTree: tree}, DefaultOptions())
+	if len(graph.Nodes) != 2 || graph.Nodes[0].InclusiveBytes != 100 || len(graph.Edges) != 2 {
+		t.Fatalf("callgraph test error: unexpected graph %+v", graph)
+	}
+	for _, edge := range graph.Edges {
+		if edge.Bytes != 100 {
+			t.Fatalf("callgraph test error: recursive edge %+v accounted for more than once", edge)
+		}
+	}
+}
+
+func TestWriteDOT_OK(t *testing.T) {
+	tree := &heaptree.HeapTree{Memory: 100, Frame: &heaptree.Frame{Address: "root", Func: "heap allocation functions"}, HeapAllocationLeafs: []*heaptree.HeapTree{
+		{Memory: 100, Frame: &heaptree.Frame{Address: "0x1", Func: `operator "" _kb(char const*)`}, HeapAllocationLeafs: []*heaptree.HeapTree{
+			{Memory: 100, Frame: &heaptree.Frame{Address: "0x2", Func: `C:\main`}},
+		}},
+	}}
+	graph := Build(&snapshot.Snapshot{Id: 7, MemHeapB: 100, HeapTree: tree}, DefaultOptions())
+
+	var b bytes.Buffer
+	if err := graph.WriteDOT(&b); err != nil {
+		t.Fatalf("callgraph test error: %v", err)
+	}
+	dot := b.String()
+
+	for _, expected := range []string{
+		`digraph "massif" {`,
+		`N1 [label="operator \"\" _kb(char const*)\nself 100 B (100.00%)\nof 100 B (100.00%)"`,
+		`N2 [label="C:\\main\nself 0 B (0.00%)\nof 100 B (100.00%)"`,
+		`N2 -> N1 [label=" 100 B"`,
+		`fillcolor="#d62728"`,
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Fatalf("callgraph test error: %s not found in %s", expected, dot)
+		}
+	}
+	if !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("callgraph test error: unterminated graph %s", dot)
+	}
+}
+
+func TestWriteSVG_OK(t *testing.T) {
+	graph := Build(peakSnapshot(t), DefaultOptions())
+
+	// A fake dot binary, echoing its input in an SVG comment
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = -Tsvg ] || exit 1\necho '<svg><!--'\n/bin/cat\necho '--></svg>'\n"
+	if err := os.WriteFile(filepath.Join(dir, "dot"), []byte(script), 0o755); err != nil {
+		t.Fatalf("callgraph test error: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	var b bytes.Buffer
+	if err := graph.WriteSVG(&b); err != nil {
+		t.Fatalf("callgraph test error: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "<svg><!--\ndigraph") || !strings.HasSuffix(b.String(), "--></svg>\n") {
+		t.Fatalf("callgraph test error: unexpected SVG %s", b.String())
+	}
+}
+
+func TestWriteSVG_KO(t *testing.T) {
+	graph := Build(peakSnapshot(t), DefaultOptions())
+
+	t.Setenv("PATH", t.TempDir())
+	if err := graph.WriteSVG(&bytes.Buffer{}); !errors.Is(err, ErrDotNotFound) {
+		t.Fatalf("callgraph test error: expected a dot not found error, found %v", err)
+	}
+
+	// A dot binary failing
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dot"), []byte("#!/bin/sh\necho 'syntax error' >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatalf("callgraph test error: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	if err := graph.WriteSVG(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "syntax error") {
+		t.Fatalf("callgraph test error: expected the dot error, found %v", err)
+	}
+}
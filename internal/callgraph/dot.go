@@ -0,0 +1,111 @@
+package callgraph
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/timeline"
+)
+
+// Returned when rendering a graph without the dot binary of Graphviz
+var ErrDotNotFound = errors.New("callgraph error: the dot binary of Graphviz is not in the PATH")
+
+// Writes the graph in the DOT language of Graphviz, as pprof does: the larger the self bytes of a function, the larger its font,
+// the larger its inclusive bytes, the redder its box, and the more bytes an edge carries, the thicker it is
+func (graph Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph \"massif\" {\n")
+	b.WriteString("\tnode [shape=box style=filled fontname=\"sans-serif\" fillcolor=\"#eeeeee\"]\n")
+	b.WriteString("\tedge [fontname=\"sans-serif\" fontsize=10]\n")
+
+	legend := fmt.Sprintf("Snapshot %d: %s of heap\\lShowing %d of %d functions, %d edges dropped\\l",
+		graph.SnapshotID, timeline.FormatBytes(graph.Total), len(graph.Nodes), len(graph.Nodes)+graph.DroppedNodes, graph.DroppedEdges)
+	fmt.Fprintf(&b, "\tlabel=\"%s\" labelloc=t labeljust=l fontname=\"sans-serif\"\n", legend)
+
+	maxSelf, maxEdge := 0, 0
+	for _, node := range graph.Nodes {
+		maxSelf = max(maxSelf, node.SelfBytes)
+	}
+	for _, edge := range graph.Edges {
+		maxEdge = max(maxEdge, edge.Bytes)
+	}
+
+	ids := make(map[string]int, len(graph.Nodes))
+	for i, node := range graph.Nodes {
+		ids[node.Func] = i + 1
+
+		fontSize := 8.0
+		if maxSelf > 0 {
+			fontSize += 32 * math.Sqrt(float64(node.SelfBytes)/float64(maxSelf))
+		}
+		label := fmt.Sprintf("%s\\nself %s (%s)\\nof %s (%.2f%%)",
+			escape(node.Func), timeline.FormatBytes(node.SelfBytes), graph.percent(node.SelfBytes), timeline.FormatBytes(node.InclusiveBytes), node.Percent)
+		fmt.Fprintf(&b, "\tN%d [label=\"%s\" tooltip=\"%s\" fontsize=%.1f fillcolor=\"%s\"]\n",
+			i+1, label, escape(node.Func), fontSize, color(node.Percent/100))
+	}
+
+	for _, edge := range graph.Edges {
+		penWidth := 1.0
+		if maxEdge > 0 {
+			penWidth += 5 * float64(edge.Bytes) / float64(maxEdge)
+		}
+		fmt.Fprintf(&b, "\tN%d -> N%d [label=\" %s\" tooltip=\"%s -> %s (%s)\" penwidth=%.2f weight=%d]\n",
+			ids[edge.Caller], ids[edge.Callee], timeline.FormatBytes(edge.Bytes), escape(edge.Caller), escape(edge.Callee),
+			timeline.FormatBytes(edge.Bytes), penWidth, 1+100*edge.Bytes/max(graph.Total, 1))
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Renders the graph as SVG with the dot binary of Graphviz, and returns ErrDotNotFound when it is not installed
+func (graph Graph) WriteSVG(w io.Writer) error {
+	path, err := exec.LookPath("dot")
+	if err != nil {
+		return ErrDotNotFound
+	}
+
+	var dot, stderr bytes.Buffer
+	if err := graph.WriteDOT(&dot); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path, "-Tsvg")
+	cmd.Stdin = &dot
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("callgraph error: dot failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Returns the share of the heap as a percentage, e.g. "12.34%"
+func (graph Graph) percent(bytes int) string {
+	if graph.Total == 0 {
+		return "0.00%"
+	}
+	return fmt.Sprintf("%.2f%%", 100*float64(bytes)/float64(graph.Total))
+}
+
+// Returns the fill color of a node holding the given fraction of the heap, from light gray to red
+func color(fraction float64) string {
+	fraction = min(max(fraction, 0), 1)
+	r := 0xee + (0xd6-0xee)*fraction
+	g := 0xee + (0x27-0xee)*fraction
+	bl := 0xee + (0x28-0xee)*fraction
+	return fmt.Sprintf("#%02x%02x%02x", int(r), int(g), int(bl))
+}
+
+// Escapes a string for a double quoted DOT string
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,106 @@
+package callgrind
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+	"github.com/MohamTahaB/massif-miner/internal/top"
+)
+
+// Name of the event of the exported files
+const Event = "HeapB"
+
+// Stands for the object or the file of a frame when massif does not know it
+const unknown = "???"
+
+// Define the compressed names of a callgrind file, e.g. "fn=(1) main" the first time and "fn=(1)" afterwards
+type names map[string]int
+
+// Returns the compressed form of the name
+func (n names) compress(name string) string {
+	if id, ok := n[name]; ok {
+		return "(" + strconv.Itoa(id) + ")"
+	}
+	n[name] = len(n) + 1
+	return "(" + strconv.Itoa(len(n)) + ") " + name
+}
+
+// Writes the heap tree of a detailed snapshot in the callgrind format, for KCachegrind and the callgrind tools, the event being the heap bytes.
+// Functions calling an allocation function directly hold the bytes they allocate as their self cost, and every other node adds a call to its parent, costing its bytes.
+// Massif does not count calls, each call path counts as one. Positions are the source lines of the frames, 0 when massif only knows their object
+func Write(w io.Writer, log *outlog.OutLog, ss *snapshot.Snapshot) error {
+	if ss.HeapTree == nil {
+		return fmt.Errorf("callgrind error: snapshot %d has no heap tree", ss.Id)
+	}
+
+	var b strings.Builder
+	b.WriteString("# callgrind format\n")
+	b.WriteString("version: 1\n")
+	b.WriteString("creator: massif-miner\n")
+	fmt.Fprintf(&b, "cmd: %s\n", oneLine(log.Cmd))
+	fmt.Fprintf(&b, "desc: Massif options: %s\n", oneLine(log.Desc))
+	fmt.Fprintf(&b, "desc: Snapshot: %d, at time %d (%s)\n", ss.Id, ss.Time, log.TimeUnit)
+	b.WriteString("positions: line\n")
+	fmt.Fprintf(&b, "events: %s\n", Event)
+	fmt.Fprintf(&b, "event: %s : Heap bytes\n", Event)
+
+	objects, files, functions := names{}, names{}, names{}
+	totals := 0
+
+	ss.HeapTree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+		// The root stands for the allocation functions, which hold no cost of their own
+		if len(ancestors) == 0 {
+			return
+		}
+
+		file, line := splitLocation(node.Location)
+		fmt.Fprintf(&b, "\nob=%s\nfl=%s\nfn=%s\n", objects.compress(object(node)), files.compress(file), functions.compress(top.Label(node)))
+
+		if len(ancestors) == 1 {
+			fmt.Fprintf(&b, "%d %d\n", line, node.Memory)
+			totals += node.Memory
+			return
+		}
+
+		callee := ancestors[len(ancestors)-1]
+		calleeFile, calleeLine := splitLocation(callee.Location)
+		fmt.Fprintf(&b, "cob=%s\ncfi=%s\ncfn=%s\n", objects.compress(object(callee)), files.compress(calleeFile), functions.compress(top.Label(callee)))
+		fmt.Fprintf(&b, "calls=1 %d\n%d %d\n", calleeLine, line, node.Memory)
+	})
+
+	fmt.Fprintf(&b, "\ntotals: %d\n", totals)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Returns the object of the frame of the node
+func object(node *heaptree.HeapTree) string {
+	if node.FuncFullDesc == "" {
+		return unknown
+	}
+	return node.FuncFullDesc
+}
+
+// Splits a frame location, e.g. "dl-init.c:117", into its file and line. The line is 0 when missing
+func splitLocation(location string) (string, int) {
+	if location == "" {
+		return unknown, 0
+	}
+	if i := strings.LastIndexByte(location, ':'); i > 0 {
+		if line, err := strconv.Atoi(location[i+1:]); err == nil && line >= 0 {
+			return location[:i], line
+		}
+	}
+	return location, 0
+}
+
+// Keeps header values on a single line
+func oneLine(s string) string {
+	return strings.NewReplacer("\n", " ", "\r", " ").Replace(s)
+}
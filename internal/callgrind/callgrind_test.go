@@ -0,0 +1,176 @@
+package callgrind
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("callgrind test error: %v", err)
+	}
+	return log
+}
+
+// Define what a callgrind reader gathers out of a file: the self cost of the functions, and the cost of their calls
+type profile struct {
+	header map[string][]string
+	self   map[string]int
+	calls  map[[2]string]int
+	// Source line of the function calls, by caller and callee
+	lines  map[[2]string][2]int
+	totals int
+}
+
+var compressed = regexp.MustCompile(`^\((\d+)\)(?: (.*))?$`)
+
+// Reads a callgrind file, resolving its compressed names
+func readProfile(t *testing.T, content []byte) profile {
+	p := profile{header: make(map[string][]string), self: make(map[string]int), calls: make(map[[2]string]int), lines: make(map[[2]string][2]int)}
+	namespaces := map[string]map[string]string{"ob": {}, "fl": {}, "fn": {}}
+	namespace := map[string]string{"ob": "ob", "cob": "ob", "fl": "fl", "cfi": "fl", "fn": "fn", "cfn": "fn"}
+	current := map[string]string{}
+	calleeLine := -1
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok && namespace[key] != "" {
+			match := compressed.FindStringSubmatch(value)
+			if match == nil {
+				t.Fatalf("callgrind test error: uncompressed name %s", line)
+			}
+			names := namespaces[namespace[key]]
+			if match[2] != "" {
+				if _, defined := names[match[1]]; defined {
+					t.Fatalf("callgrind test error: name %s defined twice", line)
+				}
+				names[match[1]] = match[2]
+			}
+			if _, defined := names[match[1]]; !defined {
+				t.Fatalf("callgrind test error: undefined name %s", line)
+			}
+			current[key] = names[match[1]]
+			continue
+		}
+
+		if value, ok := strings.CutPrefix(line, "calls="); ok {
+			fields := strings.Fields(value)
+			calleeLine, _ = strconv.Atoi(fields[1])
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			p.header[key] = append(p.header[key], value)
+			continue
+		}
+
+		fields := strings.Fields(line)
+		position, _ := strconv.Atoi(fields[0])
+		cost, err := strconv.Atoi(fields[1])
+		if len(fields) != 2 || err != nil {
+			t.Fatalf("callgrind test error: unexpected cost line %s", line)
+		}
+		if calleeLine >= 0 {
+			call := [2]string{current["fn"], current["cfn"]}
+			p.calls[call] += cost
+			p.lines[call] = [2]int{position, calleeLine}
+			calleeLine = -1
+		} else {
+			p.self[current["fn"]] += cost
+		}
+	}
+
+	p.totals, _ = strconv.Atoi(p.header["totals"][0])
+	return p
+}
+
+func TestWrite_OK(t *testing.T) {
+
+	log := parseArtifact(t)
+
+	var b bytes.Buffer
+	if err := Write(&b, &log, &log.Snapshots[45]); err != nil {
+		t.Fatalf("callgrind test error: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "# callgrind format\nversion: 1\n") {
+		t.Fatalf("callgrind test error: unexpected header %s", b.String())
+	}
+	p := readProfile(t, b.Bytes())
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if p.header["events"][0] != Event || p.header["cmd"][0] != "./alloc_dealloc" || p.header["positions"][0] != "line" || p.totals != 165527 {
+		t.Fatalf("callgrind test error: unexpected header %v", p.header)
+	}
+	if p.self["allocateAndDeallocate()"] != 90775 || p.self["main"] != 0 {
+		t.Fatalf("callgrind test error: unexpected self costs %v", p.self)
+	}
+	selfTotal := 0
+	for _, cost := range p.self {
+		selfTotal += cost
+	}
+	if selfTotal != p.totals {
+		t.Fatalf("callgrind test error: self costs sum up to %d out of %d", selfTotal, p.totals)
+	}
+
+	// Frames with a source location are positioned at their line
+	if call := [2]string{"main", "allocateAndDeallocate()"}; p.calls[call] != 92823 || p.lines[call] != [2]int{0, 0} {
+		t.Fatalf("callgrind test error: unexpected call %v: %d at %v", call, p.calls[call], p.lines[call])
+	}
+	if call := [2]string{"_dl_init", "call_init"}; p.calls[call] != 72704 || p.lines[call] != [2]int{117, 33} {
+		t.Fatalf("callgrind test error: unexpected call %v: %d at %v", call, p.calls[call], p.lines[call])
+	}
+}
+
+func TestWrite_KO(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, &outlog.OutLog{}, &snapshot.Snapshot{Id: 3}); err == nil {
+		t.Fatal("callgrind test error: snapshot with no heap tree written without error")
+	}
+}
+
+func TestSplitLocation_OK(t *testing.T) {
+	locations := map[string]struct {
+		file string
+		line int
+	}{
+		"dl-init.c:117":      {"dl-init.c", 117},
+		"C:/src/main.cpp:12": {"C:/src/main.cpp", 12},
+		"main.cpp":           {"main.cpp", 0},
+		"main.cpp:x":         {"main.cpp:x", 0},
+		"":                   {unknown, 0},
+	}
+
+	for location, expected := range locations {
+		if file, line := splitLocation(location); file != expected.file || line != expected.line {
+			t.Fatalf("callgrind test error: %q split into %q and %d", location, file, line)
+		}
+	}
+
+	// Unresolved functions are told apart by their object
+	node := &heaptree.HeapTree{Frame: &heaptree.Frame{Func: "???", FuncFullDesc: "/lib/libx.so"}}
+	if object(node) != "/lib/libx.so" || object(&heaptree.HeapTree{Frame: &heaptree.Frame{Func: "f"}}) != unknown {
+		t.Fatal("callgrind test error: unexpected objects")
+	}
+}
@@ -0,0 +1,101 @@
+package chrometrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+)
+
+// Instructions or bytes per microsecond of the synthetic clock when not specified, i.e. one per nanosecond
+const DefaultTicksPerMicrosecond = 1000
+
+// Define the options of a trace
+type Options struct {
+	// Instructions or bytes per microsecond of the synthetic clock of the logs not timed in milliseconds, DefaultTicksPerMicrosecond when not positive
+	TicksPerMicrosecond float64
+	// Process and thread ids of the events, e.g. to match the ones of a CPU trace
+	PID int
+	TID int
+}
+
+// Define an event of the Trace Event Format, as read by Perfetto and chrome://tracing
+type Event struct {
+	Name string `json:"name"`
+	Cat  string `json:"cat,omitempty"`
+	// Phase of the event: "C" for counters, "i" for instant events and "M" for metadata
+	Ph string `json:"ph"`
+	// Timestamp, in microseconds
+	Ts  float64 `json:"ts"`
+	Pid int     `json:"pid"`
+	Tid int     `json:"tid"`
+	// Scope of the instant events, "p" standing for the whole process
+	S    string         `json:"s,omitempty"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// Define a trace in the JSON object format of the Trace Event Format
+type Trace struct {
+	TraceEvents     []Event           `json:"traceEvents"`
+	DisplayTimeUnit string            `json:"displayTimeUnit"`
+	Metadata        map[string]string `json:"metadata"`
+}
+
+// Builds the trace of the log: a "memory" counter with the heap, extra heap and stacks bytes of every snapshot, stacks only when massif profiled them,
+// and instant events marking the peak and the detailed snapshots.
+// Times in milliseconds are converted as is, while instructions and bytes are mapped to a synthetic clock of the options ticks per microsecond
+func Build(log *outlog.OutLog, opts Options) Trace {
+	ticks := opts.TicksPerMicrosecond
+	if ticks <= 0 {
+		ticks = DefaultTicksPerMicrosecond
+	}
+	timestamp := func(t int) float64 {
+		if log.TimeUnit == outlog.MS {
+			return float64(t) * 1000
+		}
+		return float64(t) / ticks
+	}
+
+	clock := "milliseconds"
+	if log.TimeUnit != outlog.MS {
+		clock = fmt.Sprintf("synthetic, %g %s per microsecond", ticks, log.TimeUnit)
+	}
+	trace := Trace{
+		TraceEvents: []Event{
+			{Name: "process_name", Ph: "M", Pid: opts.PID, Tid: opts.TID, Args: map[string]any{"name": "massif " + log.Cmd}},
+		},
+		DisplayTimeUnit: "ms",
+		Metadata: map[string]string{
+			"cmd":      log.Cmd,
+			"desc":     log.Desc,
+			"timeUnit": log.TimeUnit.String(),
+			"clock":    clock,
+		},
+	}
+
+	for _, ss := range log.Snapshots {
+		args := map[string]any{"heap": ss.MemHeapB, "extra heap": ss.MemHeapExtraB}
+		if ss.StacksProfiled {
+			args["stacks"] = ss.MemStacksB
+		}
+		trace.TraceEvents = append(trace.TraceEvents, Event{Name: "memory", Cat: "massif", Ph: "C", Ts: timestamp(ss.Time), Pid: opts.PID, Tid: opts.TID, Args: args})
+
+		total := ss.MemHeapB + ss.MemHeapExtraB + ss.MemStacksB
+		if ss.Id == log.Peak.MaxID {
+			trace.TraceEvents = append(trace.TraceEvents, Event{Name: "peak", Cat: "massif", Ph: "i", Ts: timestamp(ss.Time), Pid: opts.PID, Tid: opts.TID, S: "p",
+				Args: map[string]any{"snapshot": ss.Id, "total": total}})
+		}
+		if ss.HeapTree != nil {
+			trace.TraceEvents = append(trace.TraceEvents, Event{Name: fmt.Sprintf("detailed snapshot %d", ss.Id), Cat: "massif", Ph: "i", Ts: timestamp(ss.Time), Pid: opts.PID, Tid: opts.TID, S: "p",
+				Args: map[string]any{"snapshot": ss.Id, "total": total}})
+		}
+	}
+
+	return trace
+}
+
+// Writes the trace of the log as JSON, to be opened in Perfetto or chrome://tracing
+func Write(w io.Writer, log *outlog.OutLog, opts Options) error {
+	return json.NewEncoder(w).Encode(Build(log, opts))
+}
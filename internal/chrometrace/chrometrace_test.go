@@ -0,0 +1,109 @@
+package chrometrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("trace test error: %v", err)
+	}
+	return log
+}
+
+func TestBuild_OK(t *testing.T) {
+
+	log := parseArtifact(t)
+
+	trace := Build(&log, Options{PID: 42, TID: 7})
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	phases := make(map[string]int)
+	var peak Event
+	for _, event := range trace.TraceEvents {
+		phases[event.Ph]++
+		if event.Pid != 42 || event.Tid != 7 {
+			t.Fatalf("trace test error: unexpected ids in %+v", event)
+		}
+		if event.Name == "peak" {
+			peak = event
+		}
+		if _, ok := event.Args["stacks"]; ok {
+			t.Fatalf("trace test error: stacks reported while not profiled in %+v", event)
+		}
+	}
+	if phases["M"] != 1 || phases["C"] != 60 || phases["i"] != 9 {
+		t.Fatalf("trace test error: unexpected events %v", phases)
+	}
+	if peak.Ts != 8755.83 || peak.S != "p" || peak.Args["snapshot"] != 45 || peak.Args["total"] != 168544 {
+		t.Fatalf("trace test error: unexpected peak %+v", peak)
+	}
+	if trace.Metadata["clock"] != "synthetic, 1000 i per microsecond" {
+		t.Fatalf("trace test error: unexpected metadata %v", trace.Metadata)
+	}
+
+	// Faster synthetic clock
+	if counter := Build(&log, Options{TicksPerMicrosecond: 2000}).TraceEvents[2]; counter.Ph != "C" || counter.Ts != 2279725.0/2000 {
+		t.Fatalf("trace test error: unexpected counter %+v", counter)
+	}
+}
+
+func TestWrite_Milliseconds_OK(t *testing.T) {
+	log := outlog.OutLog{
+		Cmd:      "./app",
+		TimeUnit: outlog.MS,
+		Snapshots: []snapshot.Snapshot{
+			{Id: 0, Time: 0, MemStacksB: 8, StacksProfiled: true},
+			{Id: 1, Time: 25, MemHeapB: 100, MemHeapExtraB: 8, MemStacksB: 16, StacksProfiled: true, HeapTree: &heaptree.HeapTree{Memory: 100}, IsPeak: true},
+		},
+		StacksProfiled: true,
+	}
+	log.DetectPeak()
+
+	var b bytes.Buffer
+	if err := Write(&b, &log, Options{TicksPerMicrosecond: 5}); err != nil {
+		t.Fatalf("trace test error: %v", err)
+	}
+
+	var trace struct {
+		TraceEvents []struct {
+			Name string         `json:"name"`
+			Ph   string         `json:"ph"`
+			Ts   float64        `json:"ts"`
+			Args map[string]any `json:"args"`
+		} `json:"traceEvents"`
+		DisplayTimeUnit string `json:"displayTimeUnit"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &trace); err != nil {
+		t.Fatalf("trace test error: %v", err)
+	}
+
+	// Metadata, then the counter, peak and detailed snapshot of the second snapshot
+	if len(trace.TraceEvents) != 5 || trace.DisplayTimeUnit != "ms" {
+		t.Fatalf("trace test error: unexpected trace %s", b.String())
+	}
+	counter := trace.TraceEvents[2]
+	if counter.Ph != "C" || counter.Ts != 25000 || counter.Args["heap"] != 100.0 || counter.Args["extra heap"] != 8.0 || counter.Args["stacks"] != 16.0 {
+		t.Fatalf("trace test error: unexpected counter %+v", counter)
+	}
+	if trace.TraceEvents[3].Name != "peak" || trace.TraceEvents[4].Name != "detailed snapshot 1" || trace.TraceEvents[4].Ts != 25000 {
+		t.Fatalf("trace test error: unexpected instant events %s", b.String())
+	}
+}
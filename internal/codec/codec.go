@@ -0,0 +1,388 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Magic bytes starting an encoded log
+const Magic = "MMBIN"
+
+// Version of the binary format, logs of other versions are rejected
+const Version = 1
+
+// Flags of an encoded snapshot
+const (
+	flagStacksProfiled = 1 << iota
+	flagIsPeak
+	flagHeapTree
+)
+
+// Limits applied when decoding, so that a corrupted input does not exhaust the memory or the stack
+const (
+	maxStringBytes = 64 << 20
+	maxPrealloc    = 1 << 16
+	maxTreeDepth   = 1 << 16
+)
+
+// Returned when decoding an input that is not an encoded log
+var ErrNotEncoded = errors.New("codec error: not an encoded log")
+
+// Checks whether the header starts an encoded log, e.g. from the first bytes of a file
+func IsEncoded(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(Magic))
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+
+	strings map[string]int
+	// Frame ids of the frames, the ones of the log frame table included
+	frames map[heaptree.Frame]int
+}
+
+// Writes the log in a compact binary format, to be loaded faster than the massif text it comes from. The log is left untouched.
+//
+// The format starts with Magic and Version, then holds, with varints for integers:
+//   - the header: desc, cmd, time unit and whether stacks are profiled
+//   - the peak
+//   - a string table, holding the distinct strings of the frames
+//   - a frame table, each frame being the string ids of its address, func, func full desc and location.
+//     Its first frames are the frame table of the log, the others the frames of the nodes that are not in it
+//   - the snapshots, their times being delta encoded, and their heap trees written depth first as frame ids, memories and children counts
+func Encode(w io.Writer, log *outlog.OutLog) error {
+	enc := &encoder{
+		w:       bufio.NewWriter(w),
+		strings: make(map[string]int),
+		frames:  make(map[heaptree.Frame]int, log.Frames.Len()),
+	}
+
+	enc.w.WriteString(Magic)
+	enc.uvarint(Version)
+
+	enc.string(log.Desc)
+	enc.string(log.Cmd)
+	enc.uvarint(uint64(log.TimeUnit))
+	enc.bool(log.StacksProfiled)
+
+	enc.varint(log.Peak.FlaggedID)
+	enc.varint(log.Peak.FlaggedTotal)
+	enc.varint(log.Peak.MaxID)
+	enc.varint(log.Peak.MaxTotal)
+	enc.uvarint(math.Float64bits(log.Peak.Inaccuracy))
+	enc.varint(log.Peak.BandLow)
+	enc.varint(log.Peak.BandHigh)
+	enc.bool(log.Peak.Consistent)
+
+	// Gather the frames, the log frame table first so that its ids are kept
+	var frames []heaptree.Frame
+	var stringTable []string
+	addFrame := func(frame heaptree.Frame) {
+		if _, ok := enc.frames[frame]; ok {
+			return
+		}
+		frames = append(frames, frame)
+		enc.frames[frame] = len(frames)
+		for _, s := range []string{frame.Address, frame.Func, frame.FuncFullDesc, frame.Location} {
+			if _, ok := enc.strings[s]; !ok {
+				stringTable = append(stringTable, s)
+				enc.strings[s] = len(stringTable) - 1
+			}
+		}
+	}
+	for id := 1; id <= log.Frames.Len(); id++ {
+		frame, _ := log.Frames.Frame(id)
+		addFrame(*frame)
+	}
+	interned := len(frames)
+	for i := range log.Snapshots {
+		log.Snapshots[i].HeapTree.Walk(func(node *heaptree.HeapTree, _ []*heaptree.HeapTree) {
+			addFrame(*node.Frame)
+		})
+	}
+
+	enc.uvarint(uint64(len(stringTable)))
+	for _, s := range stringTable {
+		enc.string(s)
+	}
+	enc.uvarint(uint64(len(frames)))
+	enc.uvarint(uint64(interned))
+	for _, frame := range frames {
+		enc.uvarint(uint64(enc.strings[frame.Address]))
+		enc.uvarint(uint64(enc.strings[frame.Func]))
+		enc.uvarint(uint64(enc.strings[frame.FuncFullDesc]))
+		enc.uvarint(uint64(enc.strings[frame.Location]))
+	}
+
+	enc.uvarint(uint64(len(log.Snapshots)))
+	previousTime := 0
+	for i := range log.Snapshots {
+		ss := &log.Snapshots[i]
+		flags := uint64(0)
+		if ss.StacksProfiled {
+			flags |= flagStacksProfiled
+		}
+		if ss.IsPeak {
+			flags |= flagIsPeak
+		}
+		if ss.HeapTree != nil {
+			flags |= flagHeapTree
+		}
+
+		enc.varint(ss.Id)
+		enc.varint(ss.Time - previousTime)
+		enc.varint(ss.MemHeapB)
+		enc.varint(ss.MemHeapExtraB)
+		enc.varint(ss.MemStacksB)
+		enc.uvarint(flags)
+		if ss.HeapTree != nil {
+			enc.tree(ss.HeapTree)
+		}
+		previousTime = ss.Time
+	}
+
+	return enc.w.Flush()
+}
+
+func (enc *encoder) tree(node *heaptree.HeapTree) {
+	enc.uvarint(uint64(enc.frames[*node.Frame]))
+	enc.varint(node.ID)
+	enc.varint(node.Memory)
+	enc.uvarint(uint64(len(node.HeapAllocationLeafs)))
+	for _, leaf := range node.HeapAllocationLeafs {
+		enc.tree(leaf)
+	}
+}
+
+// Write errors are kept by the buffered writer, and returned when flushing
+func (enc *encoder) uvarint(v uint64) {
+	enc.w.Write(binary.AppendUvarint(enc.buf[:0], v))
+}
+
+func (enc *encoder) varint(v int) {
+	enc.w.Write(binary.AppendVarint(enc.buf[:0], int64(v)))
+}
+
+func (enc *encoder) bool(v bool) {
+	if v {
+		enc.w.WriteByte(1)
+	} else {
+		enc.w.WriteByte(0)
+	}
+}
+
+func (enc *encoder) string(s string) {
+	enc.uvarint(uint64(len(s)))
+	enc.w.WriteString(s)
+}
+
+type decoder struct {
+	r   *bufio.Reader
+	err error
+
+	frames []*heaptree.Frame
+	// Number of leading frames that are in the frame table of the log
+	interned int
+}
+
+// Reads a log written by Encode
+func Decode(r io.Reader) (outlog.OutLog, error) {
+	dec := &decoder{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(dec.r, magic); err != nil || string(magic) != Magic {
+		return outlog.OutLog{}, ErrNotEncoded
+	}
+	if version := dec.uvarint(); dec.err == nil && version != Version {
+		return outlog.OutLog{}, fmt.Errorf("codec error: version %d not supported", version)
+	}
+
+	var log outlog.OutLog
+	log.Desc = dec.string()
+	log.Cmd = dec.string()
+	if log.TimeUnit = outlog.TimeUnit(dec.uvarint()); !log.TimeUnit.IsResolved() {
+		dec.fail(fmt.Errorf("time unit %s not supported", log.TimeUnit))
+	}
+	log.StacksProfiled = dec.bool()
+
+	log.Peak.FlaggedID = dec.varint()
+	log.Peak.FlaggedTotal = dec.varint()
+	log.Peak.MaxID = dec.varint()
+	log.Peak.MaxTotal = dec.varint()
+	log.Peak.Inaccuracy = math.Float64frombits(dec.uvarint())
+	log.Peak.BandLow = dec.varint()
+	log.Peak.BandHigh = dec.varint()
+	log.Peak.Consistent = dec.bool()
+
+	n := dec.length()
+	stringTable := make([]string, 0, min(n, maxPrealloc))
+	for i := 0; i < n && dec.err == nil; i++ {
+		stringTable = append(stringTable, dec.string())
+	}
+	stringAt := func() string {
+		id := dec.uvarint()
+		if id >= uint64(len(stringTable)) {
+			dec.fail(fmt.Errorf("string %d out of the table", id))
+			return ""
+		}
+		return stringTable[id]
+	}
+
+	n = dec.length()
+	dec.frames = make([]*heaptree.Frame, 0, min(n, maxPrealloc))
+	if dec.interned = dec.length(); dec.interned > n {
+		dec.fail(fmt.Errorf("%d frames in the table of the log, out of %d", dec.interned, n))
+	}
+	for i := 0; i < n && dec.err == nil; i++ {
+		frame := &heaptree.Frame{Address: stringAt(), Func: stringAt(), FuncFullDesc: stringAt(), Location: stringAt()}
+		if i < dec.interned {
+			// Nodes share the table frames, as when parsed
+			var id int
+			if id, frame = log.Frames.Intern(*frame); id != i+1 {
+				dec.fail(fmt.Errorf("duplicate frame %d", i+1))
+			}
+		}
+		dec.frames = append(dec.frames, frame)
+	}
+
+	if n = dec.length(); n > 0 {
+		log.Snapshots = make([]snapshot.Snapshot, 0, min(n, maxPrealloc))
+	}
+	previousTime := 0
+	for i := 0; i < n && dec.err == nil; i++ {
+		ss := snapshot.Snapshot{
+			Id:            dec.varint(),
+			Time:          previousTime + dec.varint(),
+			MemHeapB:      dec.varint(),
+			MemHeapExtraB: dec.varint(),
+			MemStacksB:    dec.varint(),
+		}
+		flags := dec.uvarint()
+		ss.StacksProfiled = flags&flagStacksProfiled != 0
+		ss.IsPeak = flags&flagIsPeak != 0
+		if flags&flagHeapTree != 0 {
+			ss.HeapTree = dec.tree(0)
+		}
+		log.Snapshots = append(log.Snapshots, ss)
+		previousTime = ss.Time
+	}
+
+	if dec.err != nil {
+		return outlog.OutLog{}, dec.err
+	}
+	return log, nil
+}
+
+func (dec *decoder) tree(depth int) *heaptree.HeapTree {
+	if depth > maxTreeDepth {
+		dec.fail(fmt.Errorf("heap tree deeper than %d", maxTreeDepth))
+	}
+	if dec.err != nil {
+		return nil
+	}
+
+	id := dec.uvarint()
+	if id < 1 || id > uint64(len(dec.frames)) {
+		dec.fail(fmt.Errorf("frame %d out of the table", id))
+		return nil
+	}
+	frame := dec.frames[id-1]
+	node := &heaptree.HeapTree{
+		ID:     dec.varint(),
+		Memory: dec.varint(),
+		Frame:  frame,
+	}
+	if int(id) <= dec.interned {
+		node.FrameID = int(id)
+	}
+
+	if children := dec.length(); children > 0 {
+		node.HeapAllocationLeafs = make([]*heaptree.HeapTree, 0, min(children, maxPrealloc))
+		for i := 0; i < children && dec.err == nil; i++ {
+			node.HeapAllocationLeafs = append(node.HeapAllocationLeafs, dec.tree(depth+1))
+		}
+	}
+	return node
+}
+
+// Keeps the first error, the following reads returning zero values
+func (dec *decoder) fail(err error) {
+	if dec.err != nil {
+		return
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	dec.err = fmt.Errorf("codec error: %w", err)
+}
+
+func (dec *decoder) uvarint() uint64 {
+	if dec.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		dec.fail(err)
+	}
+	return v
+}
+
+func (dec *decoder) varint() int {
+	if dec.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(dec.r)
+	if err != nil {
+		dec.fail(err)
+	}
+	return int(v)
+}
+
+func (dec *decoder) bool() bool {
+	if dec.err != nil {
+		return false
+	}
+	b, err := dec.r.ReadByte()
+	if err != nil {
+		dec.fail(err)
+	}
+	return b == 1
+}
+
+// Reads the length of a list. Lists are only preallocated up to maxPrealloc items, a corrupted length failing on the missing items instead
+func (dec *decoder) length() int {
+	n := dec.uvarint()
+	if n > math.MaxInt32 {
+		dec.fail(fmt.Errorf("list of %d items", n))
+		return 0
+	}
+	return int(n)
+}
+
+func (dec *decoder) string() string {
+	length := dec.uvarint()
+	if dec.err != nil {
+		return ""
+	}
+	if length > maxStringBytes {
+		dec.fail(fmt.Errorf("string of %d bytes", length))
+		return ""
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(dec.r, buf); err != nil {
+		dec.fail(err)
+		return ""
+	}
+	return string(buf)
+}
@@ -0,0 +1,178 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+func parseArtifact(t testing.TB, times int) ([]byte, outlog.OutLog) {
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	// Repeat the snapshots to get a larger log
+	start := bytes.Index(content, []byte("#-----------\nsnapshot="))
+	if start < 0 {
+		t.Fatal("unexpected massif.out log layout")
+	}
+	content = append(content[:start:start], bytes.Repeat(content[start:], times)...)
+
+	log, err := digger.Parse(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("codec test error: %v", err)
+	}
+	return content, log
+}
+
+func roundTrip(t *testing.T, log outlog.OutLog) []byte {
+	var encoded bytes.Buffer
+	if err := Encode(&encoded, &log); err != nil {
+		t.Fatalf("codec test error: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(encoded.Bytes()))
+	if err != nil {
+		t.Fatalf("codec test error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, log) {
+		t.Fatalf("codec test error: decoded log %+v differs from %+v", decoded, log)
+	}
+	return encoded.Bytes()
+}
+
+func TestEncode_RoundTrip_OK(t *testing.T) {
+	content, log := parseArtifact(t, 1)
+	encoded := roundTrip(t, log)
+
+	if !IsEncoded(encoded) || IsEncoded(content) {
+		t.Fatal("codec test error: encoded logs are not told apart from massif logs")
+	}
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if len(encoded) > len(content)/4 {
+		t.Fatalf("codec test error: %d bytes encoded out of a %d bytes log", len(encoded), len(content))
+	}
+}
+
+func TestEncode_RoundTrip_NotInterned_OK(t *testing.T) {
+
+	// Nodes out of the frame table of the log, e.g. in a tree built by hand
+	tree := &heaptree.HeapTree{ID: 2, Memory: 30, Frame: &heaptree.Frame{Address: "root", Func: "heap allocation functions", FuncFullDesc: "heap allocation functions"},
+		HeapAllocationLeafs: []*heaptree.HeapTree{
+			{ID: 0, Memory: 20, Frame: &heaptree.Frame{Address: "0x1", Func: "f (a.c:1)"}},
+			{ID: 0, Memory: 10, Frame: &heaptree.Frame{Address: "0x2", Func: "???", FuncFullDesc: "/lib/libx.so"}},
+		},
+	}
+	log := outlog.OutLog{
+		Desc:     "--time-unit=ms",
+		Cmd:      "./app",
+		TimeUnit: outlog.MS,
+		Snapshots: []snapshot.Snapshot{
+			{Id: 0, Time: 5, MemStacksB: 8, StacksProfiled: true},
+			{Id: 1, Time: 3, MemHeapB: 30, MemHeapExtraB: 2, HeapTree: tree, IsPeak: true},
+		},
+		StacksProfiled: true,
+	}
+	log.Frames.InternNode(tree)
+	log.DetectPeak()
+	roundTrip(t, log)
+
+	roundTrip(t, outlog.OutLog{})
+}
+
+func TestDecode_KO(t *testing.T) {
+	_, log := parseArtifact(t, 1)
+	var encoded bytes.Buffer
+	if err := Encode(&encoded, &log); err != nil {
+		t.Fatalf("codec test error: %v", err)
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("desc: --massif-out-file=massif.out.log\n"))); !errors.Is(err, ErrNotEncoded) {
+		t.Fatalf("codec test error: expected a not encoded error, found %v", err)
+	}
+
+	// Every truncation of the input fails
+	for i := len(Magic); i < encoded.Len(); i++ {
+		if _, err := Decode(bytes.NewReader(encoded.Bytes()[:i])); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("codec test error: expected an unexpected EOF at byte %d, found %v", i, err)
+		}
+	}
+
+	version := append([]byte(Magic), Version+1)
+	if _, err := Decode(bytes.NewReader(version)); err == nil {
+		t.Fatal("codec test error: unsupported version decoded without error")
+	}
+
+	// A list length that does not fit, a heap tree referencing no frame, and an unresolved time unit
+	header := append([]byte(Magic), Version, 0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0)
+	lengths := append(header[:len(header):len(header)], 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F)
+	frames := append(header[:len(header):len(header)], 0, 0, 0, 1, 0, 0, 0, 0, 0, flagHeapTree, 5)
+	unit := append(header[:len(header):len(header)], 0, 0, 0, 0)
+	unit[len(Magic)+3] = byte(outlog.AUTO)
+	for _, input := range [][]byte{lengths, frames, unit} {
+		if _, err := Decode(bytes.NewReader(input)); err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("codec test error: expected a corruption error, found %v", err)
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	// About 1.8MB of snapshots
+	content, log := parseArtifact(b, 100)
+
+	encodedJSON, err := json.Marshal(log)
+	if err != nil {
+		b.Fatalf("codec benchmark error: %v", err)
+	}
+	var encoded bytes.Buffer
+	if err := Encode(&encoded, &log); err != nil {
+		b.Fatalf("codec benchmark error: %v", err)
+	}
+
+	formats := []struct {
+		name   string
+		input  []byte
+		decode func(r io.Reader) (outlog.OutLog, error)
+	}{
+		{"text", content, digger.Parse},
+		{"json", encodedJSON, outlog.Decode},
+		{"binary", encoded.Bytes(), Decode},
+	}
+
+	for _, format := range formats {
+		b.Run(format.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := format.decode(bytes.NewReader(format.input)); err != nil {
+					b.Fatalf("codec benchmark error: %v", err)
+				}
+			}
+			b.ReportMetric(float64(len(format.input)), "input-bytes")
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	_, log := parseArtifact(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := Encode(io.Discard, &log); err != nil {
+			b.Fatalf("codec benchmark error: %v", err)
+		}
+	}
+}
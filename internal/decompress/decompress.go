@@ -0,0 +1,147 @@
+package decompress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Default maximum number of decompressed bytes, massif logs being text of a few GBs at most
+const DefaultMaxBytes = 8 << 30
+
+// Returned once the decompressed stream grows beyond its limit, e.g. for a decompression bomb
+var ErrTooLarge = errors.New("decompressed input exceeds the size limit")
+
+// Define the compression format of a stream, told apart by its magic bytes
+type Format int
+
+const (
+	PLAIN Format = iota
+	GZIP
+	ZSTD
+	BZIP2
+	XZ
+)
+
+// Returns the name of the format, e.g. "gzip"
+func (f Format) String() string {
+	switch f {
+	case GZIP:
+		return "gzip"
+	case ZSTD:
+		return "zstd"
+	case BZIP2:
+		return "bzip2"
+	case XZ:
+		return "xz"
+	default:
+		return "plain"
+	}
+}
+
+// Magic bytes of the supported formats
+var magics = []struct {
+	format Format
+	magic  []byte
+}{
+	{GZIP, []byte{0x1f, 0x8b}},
+	{ZSTD, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{BZIP2, []byte("BZh")},
+	{XZ, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+}
+
+// Tells the format of the stream from its first bytes, plain when none matches
+func Sniff(header []byte) Format {
+	for _, m := range magics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format
+		}
+	}
+	return PLAIN
+}
+
+// Returns a reader of the decompressed input, the format being sniffed out of its magic bytes, plain input being read as is.
+// Concatenated gzip members are read as a single stream. Reading more than maxBytes decompressed bytes fails with ErrTooLarge, no limit being applied when it is not positive.
+// The returned reader should be closed to release the decompressor, the input is not closed
+func NewReader(r io.Reader, maxBytes int64) (io.ReadCloser, Format, error) {
+	br := bufio.NewReader(r)
+	// Errors, e.g. an input shorter than the magic bytes, are left for the actual reads
+	header, _ := br.Peek(6)
+	format := Sniff(header)
+
+	var decompressed io.Reader
+	closer := func() error { return nil }
+
+	switch format {
+	case GZIP:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, format, fmt.Errorf("decompress error: %s: %v", format, err)
+		}
+		decompressed, closer = gz, gz.Close
+	case ZSTD:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, format, fmt.Errorf("decompress error: %s: %v", format, err)
+		}
+		decompressed, closer = zr, func() error { zr.Close(); return nil }
+	case BZIP2:
+		decompressed = bzip2.NewReader(br)
+	case XZ:
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, format, fmt.Errorf("decompress error: %s: %v", format, err)
+		}
+		decompressed = xr
+	default:
+		decompressed = br
+	}
+
+	return &limitedReader{r: decompressed, remaining: maxBytes, limited: maxBytes > 0, format: format, close: closer}, format, nil
+}
+
+// Define a reader failing once more than the remaining bytes are read, instead of silently stopping like io.LimitReader
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limited   bool
+	format    Format
+	close     func() error
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.limited && lr.remaining <= 0 {
+		// Only fail if there is more to read
+		var probe [1]byte
+		if n, err := lr.r.Read(probe[:]); n == 0 {
+			return 0, lr.wrap(err)
+		}
+		return 0, ErrTooLarge
+	}
+
+	if lr.limited && int64(len(p)) > lr.remaining {
+		p = p[:lr.remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+	return n, lr.wrap(err)
+}
+
+func (lr *limitedReader) Close() error {
+	return lr.close()
+}
+
+// Tells decompression errors apart, EOF aside
+func (lr *limitedReader) wrap(err error) error {
+	if err == nil || err == io.EOF || lr.format == PLAIN {
+		return err
+	}
+	return fmt.Errorf("decompress error: %s: %w", lr.format, err)
+}
@@ -0,0 +1,115 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func readArtifact(t *testing.T, name string) []byte {
+	content, err := os.ReadFile("../utils/artifacts/" + name)
+	if err != nil {
+		t.Fatalf("error opening the %s artifact: %v", name, err)
+	}
+	return content
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(content); err != nil {
+		t.Fatalf("decompress test error: %v", err)
+	}
+	w.Close()
+	return b.Bytes()
+}
+
+func TestNewReader_OK(t *testing.T) {
+
+	content := readArtifact(t, "massif.out.log")
+
+	var zstded bytes.Buffer
+	zw, _ := zstd.NewWriter(&zstded)
+	zw.Write(content)
+	zw.Close()
+
+	var xzed bytes.Buffer
+	xw, _ := xz.NewWriter(&xzed)
+	xw.Write(content)
+	xw.Close()
+
+	// Concatenated gzip members, as written by e.g. "gzip -c a b" or a rotating logger
+	half := len(content) / 2
+	multiMember := append(gzipped(t, content[:half]), gzipped(t, content[half:])...)
+
+	for _, tc := range []struct {
+		input  []byte
+		format Format
+	}{
+		{content, PLAIN},
+		{gzipped(t, content), GZIP},
+		{multiMember, GZIP},
+		{zstded.Bytes(), ZSTD},
+		// CAUTION: the bzip2 artifact should be regenerated along with the massif.out log
+		{readArtifact(t, "massif.out.log.bz2"), BZIP2},
+		{xzed.Bytes(), XZ},
+	} {
+		r, format, err := NewReader(bytes.NewReader(tc.input), int64(len(content)))
+		if err != nil {
+			t.Fatalf("decompress test error: %v", err)
+		}
+		if format != tc.format {
+			t.Fatalf("decompress test error: expected the %s format, found %s", tc.format, format)
+		}
+
+		output, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("decompress test error with %s: %v", format, err)
+		}
+		if !bytes.Equal(output, content) {
+			t.Fatalf("decompress test error: %s output differs from the input", format)
+		}
+	}
+}
+
+func TestNewReader_TooLarge_KO(t *testing.T) {
+
+	// A small gzip stream of many zeros, as in a decompression bomb
+	bomb := gzipped(t, make([]byte, 1<<20))
+
+	for _, input := range [][]byte{bomb, make([]byte, 1<<20)} {
+		r, _, err := NewReader(bytes.NewReader(input), 1<<10)
+		if err != nil {
+			t.Fatalf("decompress test error: %v", err)
+		}
+		if _, err := io.ReadAll(r); !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("decompress test error: expected ErrTooLarge, found %v", err)
+		}
+	}
+}
+
+func TestNewReader_Corrupt_KO(t *testing.T) {
+
+	corrupt := gzipped(t, readArtifact(t, "massif.out.log"))
+	corrupt = corrupt[:len(corrupt)/2]
+
+	r, _, err := NewReader(bytes.NewReader(corrupt), 0)
+	if err != nil {
+		t.Fatalf("decompress test error: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("decompress test error: expected an error for a truncated stream")
+	}
+
+	// The gzip header itself is checked right away
+	if _, _, err := NewReader(bytes.NewReader([]byte{0x1f, 0x8b, 0}), 0); err == nil {
+		t.Fatal("decompress test error: expected an error for a truncated header")
+	}
+}
@@ -2,11 +2,13 @@ package digger
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"regexp"
-	"strconv"
+	"os"
 
+	"github.com/MohamTahaB/massif-miner/internal/decompress"
 	"github.com/MohamTahaB/massif-miner/internal/heaptree"
 	"github.com/MohamTahaB/massif-miner/internal/outlog"
 	"github.com/MohamTahaB/massif-miner/internal/snapshot"
@@ -15,20 +17,136 @@ import (
 
 // A struct that wraps around a bufio scanner, in order to define member funcs and be able to add snapshots sequentially
 type DiggerSite struct {
-	Scanner  *bufio.Scanner
-	HTreeCtx heaptree.HeapTreeDepthCtx
+	Scanner *bufio.Scanner
+	// Maximum length of a line, see ErrLineTooLong
+	maxLineBytes int
+
+	// Frame ids of the frames seen so far, keyed by their line bytes, along with the log owning them
+	frames   map[string]int
+	framesOf *outlog.OutLog
+
+	// Heap tree nodes allocated ahead, handed over one by one to the parsed trees
+	nodes []heaptree.HeapTree
 }
 
-// Initiates a digger site instance, from an io reader passed as input
+// Delimiter of the snapshots of a massif log
+const delimiter = "#-----------"
+
+// Number of heap tree nodes allocated at once
+const nodesBlock = 256
+
+// Default maximum length of a line, heap tree lines of templated C++ frames running up to a few MBs
+const DefaultMaxLineBytes = 64 << 20
+
+// Returned when a line is longer than the maximum length of the digger site
+var ErrLineTooLong = errors.New("line too long")
+
+// Initiates a digger site instance, from an io reader passed as input, reading lines of up to DefaultMaxLineBytes
 func InitDiggerSite(r io.Reader) DiggerSite {
+	return InitDiggerSiteMaxLine(r, DefaultMaxLineBytes)
+}
+
+// Initiates a digger site instance reading lines of up to maxLineBytes, newline excluded, DefaultMaxLineBytes when not positive.
+// The line buffer only grows as needed
+func InitDiggerSiteMaxLine(r io.Reader, maxLineBytes int) DiggerSite {
+	if maxLineBytes <= 0 {
+		maxLineBytes = DefaultMaxLineBytes
+	}
+
+	scanner := bufio.NewScanner(r)
+	// The scanner needs room for the newline on top of the line
+	scanner.Buffer(make([]byte, 0, min(bufio.MaxScanTokenSize, maxLineBytes+1)), maxLineBytes+1)
+
 	return DiggerSite{
-		Scanner: bufio.NewScanner(r),
-		HTreeCtx: heaptree.HeapTreeDepthCtx{
-			HTreeDepth: make(map[int]*heaptree.HeapTree),
-		},
+		Scanner:      scanner,
+		maxLineBytes: maxLineBytes,
+	}
+}
+
+// Parses a whole massif log from the input reader, metadata and snapshots included, and detects its peak
+func Parse(r io.Reader) (outlog.OutLog, error) {
+	return ParseMaxLine(r, DefaultMaxLineBytes)
+}
+
+// Parses a whole massif log like Parse, failing with ErrLineTooLong on lines longer than maxLineBytes, DefaultMaxLineBytes when not positive
+func ParseMaxLine(r io.Reader, maxLineBytes int) (outlog.OutLog, error) {
+	dg := InitDiggerSiteMaxLine(r, maxLineBytes)
+	log := outlog.OutLog{}
+
+	if err := dg.MetaData(&log); err != nil {
+		return log, dg.readError(err)
+	}
+
+	for {
+		atEOF, err := dg.FetchSnapshot(&log)
+		if err != nil {
+			return log, dg.readError(err)
+		}
+		if atEOF {
+			log.DetectPeak()
+			return log, nil
+		}
 	}
 }
 
+// Parses the massif log at the given path like Parse, compressed logs being decompressed on the fly.
+// At most maxBytes decompressed bytes are read, see decompress.NewReader
+func ParseFile(path string, maxBytes int64) (outlog.OutLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return outlog.OutLog{}, fmt.Errorf("parse error: %w", err)
+	}
+	defer file.Close()
+
+	r, _, err := decompress.NewReader(file, maxBytes)
+	if err != nil {
+		return outlog.OutLog{}, err
+	}
+	defer r.Close()
+
+	return Parse(r)
+}
+
+// Parses a chunk of whole snapshots of a log, starting with the delimiter of its first snapshot, and appends them to the log.
+// The log metadata is left untouched, and its peak is not detected
+func FetchSnapshots(r io.Reader, log *outlog.OutLog) error {
+	dg := InitDiggerSite(r)
+	return dg.fetchSnapshots(log)
+}
+
+func (dg *DiggerSite) fetchSnapshots(log *outlog.OutLog) error {
+	// Skip the delimiter, the digger site is then where FetchSnapshot expects it
+	if !dg.Scan() {
+		return dg.readError(fmt.Errorf("snapshot error: empty chunk"))
+	}
+	if !dg.atDelimiter() {
+		return fmt.Errorf("snapshot error: a delimiter is expected at the beginning of the chunk")
+	}
+
+	for {
+		atEOF, err := dg.FetchSnapshot(log)
+		if err != nil {
+			return dg.readError(err)
+		}
+		if atEOF {
+			return nil
+		}
+	}
+}
+
+// Returns the read error of the digger site, if any, in place of the parse error it led to.
+// The scanner hands over the last partial line when the read fails, e.g. past a decompression size limit, and the read error is then the actual cause
+func (dg *DiggerSite) readError(err error) error {
+	readErr := dg.Scanner.Err()
+	if errors.Is(readErr, bufio.ErrTooLong) {
+		return fmt.Errorf("parse error: %w, a line is longer than the %d bytes maximum", ErrLineTooLong, dg.maxLineBytes)
+	}
+	if readErr != nil {
+		return fmt.Errorf("parse error: %w", readErr)
+	}
+	return err
+}
+
 // Advances the digger site scanner to the next token.
 func (dg *DiggerSite) Scan() bool {
 	return dg.Scanner.Scan()
@@ -51,71 +169,55 @@ func (dg *DiggerSite) MetaData(log *outlog.OutLog) error {
 	var desc, cmd string
 	var timeUnit outlog.TimeUnit
 
-	// init meta data regex
-	descRegex := regexp.MustCompile(`^desc: (.*)$`)
-	cmdRegex := regexp.MustCompile(`^cmd: (.*)$`)
-	timeUnitRegex := regexp.MustCompile(`^time_unit: (.*)$`)
-
-	// Get the desc submatches, and check whether a desc is found
-	descMatches := descRegex.FindStringSubmatch(dg.Text())
-
-	if len(descMatches) < 2 {
-		return fmt.Errorf("metadata error: desc not found, desc matches size is %d and the text passed to it is %s", len(descMatches), dg.Text())
+	descBytes, found := bytes.CutPrefix(dg.Scanner.Bytes(), []byte("desc: "))
+	if !found {
+		return fmt.Errorf("metadata error: desc not found, the text passed to it is %s", dg.Text())
 	}
-	desc = descMatches[1]
+	desc = string(descBytes)
+
 	// Advance the digger site to the following token
 	if !dg.Scan() {
 		return fmt.Errorf("metadata error: scan not fulfilled")
 	}
 
-	// Get the cmd submatches, and check whether a cmd is found
-	cmdMatches := cmdRegex.FindStringSubmatch(dg.Text())
-	if len(cmdMatches) < 2 {
+	cmdBytes, found := bytes.CutPrefix(dg.Scanner.Bytes(), []byte("cmd: "))
+	if !found {
 		return fmt.Errorf("metadata error: cmd not found")
 	}
-	cmd = cmdMatches[1]
+	cmd = string(cmdBytes)
+
 	// Advance the digger site to the following token
 	if !dg.Scan() {
 		return fmt.Errorf("metadata error: scan not fulfilled")
 	}
 
-	// Get the time unit submatches, and check whether a time unit is found
-	timeUnitMatches := timeUnitRegex.FindStringSubmatch(dg.Text())
-	if len(timeUnitMatches) < 2 {
+	timeUnitBytes, found := bytes.CutPrefix(dg.Scanner.Bytes(), []byte("time_unit: "))
+	if !found {
 		return fmt.Errorf("metadata error: time unit not found")
 	}
 	// Set time unit value
-	switch timeUnitMatches[1] {
-	case "i":
-		timeUnit = outlog.I
-	case "B":
-		timeUnit = outlog.B
-	case "ms":
-		timeUnit = outlog.MS
-	case "auto":
-		timeUnit = outlog.AUTO
-	default:
-		return fmt.Errorf("metadata error: time unit not supported")
+	var err error
+	if timeUnit, err = outlog.ParseTimeUnit(string(timeUnitBytes)); err != nil {
+		return fmt.Errorf("metadata error: %w", err)
 	}
 
 	log.Cmd = cmd
 	log.Desc = desc
 	log.TimeUnit = timeUnit
-
-	delimiter := "#-----------"
+	log.StacksProfiled = log.StacksOption()
 
 	// Case when there are snapshots: the first delimiter is met
 
 	if err := dg.AdvanceLine(); err != nil {
 		if dg.Scanner.Err() != nil {
-			return fmt.Errorf("metadata error: %v", dg.Scanner.Err())
+			return fmt.Errorf("metadata error: %w", dg.Scanner.Err())
 		}
 
 		// at EOF
 		return nil
 	}
 
-	if dg.Text() == delimiter {
+	if dg.atDelimiter() {
 		return nil
 	}
 
@@ -126,7 +228,7 @@ func (dg *DiggerSite) MetaData(log *outlog.OutLog) error {
 // Returns potential scanning errors
 func (dg *DiggerSite) AdvanceLine() error {
 	if !dg.Scan() {
-		return fmt.Errorf("error advancing the digger site: %v", dg.Scanner.Err())
+		return fmt.Errorf("error advancing the digger site: %w", dg.Scanner.Err())
 	}
 	return nil
 }
@@ -137,207 +239,233 @@ func (dg *DiggerSite) FetchSnapshot(log *outlog.OutLog) (bool, error) {
 
 	// TODO! consider that in case there are no snapshots, the log snapshots slice will contain an extra empty snapshot
 	ss := snapshot.Snapshot{}
-	delimiter := "#-----------"
 
 	// Handle scanning issues
 	if err := dg.AdvanceLine(); err != nil {
 		// Two possible cases, either at EOF, or at an error that should be reported
 		if dg.Scanner.Err() != nil {
-			return false, fmt.Errorf("snapshot error: %v", err)
+			return false, fmt.Errorf("snapshot error: %w", err)
 		} else {
 			return true, nil
 		}
 	}
 
 	// Expect a line of the form "snapshot=id"
-	var snapshotIDStr string
 	var err error
-
-	if snapshotIDStr, err = utils.ExtractValueOf("snapshot", dg.Text(), true); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
-	}
-
-	if ss.Id, err = strconv.Atoi(snapshotIDStr); err != nil {
-		return false, fmt.Errorf("snapshot error when converting a string: %v", err)
+	if ss.Id, err = dg.headerValue("snapshot"); err != nil {
+		return false, err
 	}
 
 	// Handle scanning issues
 	if err := dg.AdvanceLine(); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+		return false, fmt.Errorf("snapshot error: %w", err)
 	}
 
 	// a delimiter is expected
-	if dg.Text() != delimiter {
+	if !dg.atDelimiter() {
 		return false, fmt.Errorf("snapshot error: a delimiter is expected at the beginning of the snapshot")
 	}
 
-	// Handle scanning issues
-	if err := dg.AdvanceLine(); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+	// Expect the time and the memory sizes, each on its own line
+	if ss.Time, err = dg.nextHeaderValue("time"); err != nil {
+		return false, err
 	}
-
-	// Expect the time
-	var timeVal string
-	if timeVal, err = utils.ExtractValueOf("time", dg.Text(), true); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+	if ss.MemHeapB, err = dg.nextHeaderValue("mem_heap_B"); err != nil {
+		return false, err
+	}
+	if ss.MemHeapExtraB, err = dg.nextHeaderValue("mem_heap_extra_B"); err != nil {
+		return false, err
+	}
+	if ss.MemStacksB, err = dg.nextHeaderValue("mem_stacks_B"); err != nil {
+		return false, err
 	}
 
-	if ss.Time, err = strconv.Atoi(timeVal); err != nil {
-		return false, fmt.Errorf("snapshot error when converting a string: %v", err)
+	// Stack bytes mean stacks are profiled, even when the desc does not tell so. Mark the previous snapshots as well
+	if ss.MemStacksB > 0 && !log.StacksProfiled {
+		log.StacksProfiled = true
+		for i := range log.Snapshots {
+			log.Snapshots[i].StacksProfiled = true
+		}
 	}
+	ss.StacksProfiled = log.StacksProfiled
 
 	// Handle scanning issues
 	if err := dg.AdvanceLine(); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+		return false, fmt.Errorf("snapshot error: %w", err)
 	}
 
-	// expect the mem_heap_B
-	var memHeapVal string
-	if memHeapVal, err = utils.ExtractValueOf("mem_heap_B", dg.Text(), true); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+	// expect the heap tree kind
+	heapTreeVal, found := utils.ExtractValue("heap_tree", dg.Scanner.Bytes(), false)
+	if !found {
+		return false, fmt.Errorf("snapshot error: heap_tree not found in %s", dg.Text())
 	}
 
-	if ss.MemHeapB, err = strconv.Atoi(memHeapVal); err != nil {
-		return false, fmt.Errorf("snapshot error when converting a string: %v", err)
+	ss.IsPeak = string(heapTreeVal) == "peak"
+	if !ss.IsPeak && string(heapTreeVal) != "detailed" {
+		// No heap tree, a delimiter or EOF is expected
+		if !dg.Scan() {
+			if dg.Scanner.Err() != nil {
+				return false, fmt.Errorf("snapshot error: %w", dg.Scanner.Err())
+			}
+		} else if !dg.atDelimiter() {
+			return false, fmt.Errorf("snapshot error: expected a delimiter or EOF")
+		}
+		appendSnapshot(log, ss)
+		return false, nil
 	}
 
-	// Handle scanning issues
-	if err := dg.AdvanceLine(); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
-	}
+	ss.HeapTree = &heaptree.HeapTree{}
+	var atEOF bool
 
-	// expect the mem_heap_B
-	var memHeapExtraVal string
-	if memHeapExtraVal, err = utils.ExtractValueOf("mem_heap_extra_B", dg.Text(), true); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
-	}
+	// Last seen node of every depth, from the root down to the node of the previous line. The context is local to the snapshot
+	var lastAtDepth []*heaptree.HeapTree
 
-	if ss.MemHeapExtraB, err = strconv.Atoi(memHeapExtraVal); err != nil {
-		return false, fmt.Errorf("snapshot error when converting a string: %v", err)
-	}
+	for {
+		nextLine := dg.Scan()
+		// Stop if the delimiter is found, or EOF
+		if atEOF = (!nextLine && dg.Scanner.Err() == nil); atEOF {
+			break
+		}
+		if !nextLine {
+			return false, fmt.Errorf("snapshot error: %w", dg.Scanner.Err())
+		}
+		if dg.atDelimiter() {
+			break
+		}
 
-	// Handle scanning issues
-	if err := dg.AdvanceLine(); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
-	}
+		// Check the depth of the current line of the heap tree
+		htLine, depth := splitDepth(dg.Scanner.Bytes())
+
+		// Root of the Heap Tree
+		if depth == 0 {
+			lastAtDepth = append(lastAtDepth[:0], ss.HeapTree)
 
-	// expect the mem_heap_B
-	var memStacksVal string
-	if memStacksVal, err = utils.ExtractValueOf("mem_stacks_B", dg.Text(), true); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+			id, mem, frame, fn, ok := parseRootLine(htLine)
+			// Check if the line has the root id, the mem size and the func desc. Lines below the threshold have no frame, and are only looked up then
+			if !ok && isBelowThreshold(htLine) {
+				continue
+			}
+			if !ok {
+				return false, fmt.Errorf("snapshot error: unsufficient args for the root htree line: %s", htLine)
+			}
+
+			ss.HeapTree.ID = id
+			ss.HeapTree.Memory = mem
+			dg.internFrame(log, ss.HeapTree, frame, func() heaptree.Frame {
+				return heaptree.Frame{Address: "root", Func: string(fn), FuncFullDesc: string(fn)}
+			})
+			continue
+		}
+
+		line, ok := parseNodeLine(htLine)
+		if !ok && isBelowThreshold(htLine) {
+			continue
+		}
+
+		// Depth is strictly positive, a node with depth n belongs to the htree decendence of the last seen leaf of depth n-1
+		if depth > len(lastAtDepth) {
+			return false, fmt.Errorf("snapshot error: no parent for the following htree line: %s", htLine)
+		}
+		parent := lastAtDepth[depth-1]
+
+		// Check if the line has all expected info
+		if !ok {
+			return false, fmt.Errorf("snapshot error: unsufficient args for the following htree line: %s", htLine)
+		}
+
+		newHeapTreeEntry := dg.newNode()
+		newHeapTreeEntry.ID, newHeapTreeEntry.Memory = line.ID, line.Memory
+		lastAtDepth = append(lastAtDepth[:depth], newHeapTreeEntry)
+		dg.internFrame(log, newHeapTreeEntry, line.Frame, func() heaptree.Frame {
+			return heaptree.Frame{Address: string(line.Address), Func: string(line.Func), FuncFullDesc: string(line.Desc), Location: string(line.Location)}
+		})
+
+		// Add this node to the list of the descendences of the last seen node of depth -1
+		parent.HeapAllocationLeafs = append(parent.HeapAllocationLeafs, newHeapTreeEntry)
 	}
 
-	if ss.MemStacksB, err = strconv.Atoi(memStacksVal); err != nil {
-		return false, fmt.Errorf("snapshot error when converting a string: %v", err)
+	// A heap tree always has a root, e.g. a log truncated right after the heap tree kind has none
+	if ss.HeapTree.Frame == nil {
+		return false, fmt.Errorf("snapshot error: no root htree line in the detailed snapshot %d", ss.Id)
 	}
 
-	// Handle scanning issues
-	if err := dg.AdvanceLine(); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+	appendSnapshot(log, ss)
+	return atEOF, nil
+
+}
+
+// Returns a zero heap tree node, out of a block of nodes allocated together so that every node does not cost an allocation
+func (dg *DiggerSite) newNode() *heaptree.HeapTree {
+	if len(dg.nodes) == 0 {
+		dg.nodes = make([]heaptree.HeapTree, nodesBlock)
 	}
+	node := &dg.nodes[0]
+	dg.nodes = dg.nodes[1:]
+	return node
+}
 
-	// expect the mem_heap_B
-	var heapTreeVal string
-	if heapTreeVal, err = utils.ExtractValueOf("heap_tree", dg.Text(), false); err != nil {
-		return false, fmt.Errorf("snapshot error: %v", err)
+// Appends the snapshot to the log, doubling the snapshots capacity when full.
+// Long logs hold tens of thousands of snapshots, which the slower growth of append copies over and over
+func appendSnapshot(log *outlog.OutLog, ss snapshot.Snapshot) {
+	if len(log.Snapshots) == cap(log.Snapshots) {
+		grown := make([]snapshot.Snapshot, len(log.Snapshots), max(16, 2*cap(log.Snapshots)))
+		copy(grown, log.Snapshots)
+		log.Snapshots = grown
 	}
+	log.Snapshots = append(log.Snapshots, ss)
+}
 
-	ss.IsPeak = false
-	var atEOF bool
-	if heapTreeVal == "detailed" || heapTreeVal == "peak" {
+// Checks whether the digger site token is the snapshots delimiter
+func (dg *DiggerSite) atDelimiter() bool {
+	return string(dg.Scanner.Bytes()) == delimiter
+}
 
-		ss.HeapTree = &heaptree.HeapTree{}
+// Advances the digger site to the following line, and parses it as a "label=number" header line
+func (dg *DiggerSite) nextHeaderValue(label string) (int, error) {
+	if err := dg.AdvanceLine(); err != nil {
+		return 0, fmt.Errorf("snapshot error: %w", err)
+	}
+	return dg.headerValue(label)
+}
 
-		if heapTreeVal == "peak" {
-			ss.IsPeak = true
+// Parses the digger site token as a "label=number" header line
+func (dg *DiggerSite) headerValue(label string) (int, error) {
+	// Header lines are of the form "label=number" in massif logs, the label being looked up anywhere in the line otherwise
+	line := dg.Scanner.Bytes()
+	if len(line) > len(label) && line[len(label)] == '=' && string(line[:len(label)]) == label {
+		if n, rest, ok := parseDigits(line[len(label)+1:]); ok && len(rest) == 0 {
+			return n, nil
 		}
+	}
 
-		rootRegex := regexp.MustCompile(`^n(\d+): (\d+) \(([^)]+)\)`)
-		descendenceRegex := regexp.MustCompile(`^n(\d+): (\d+) ([0-9A-Fa-fx]+): (.*?) \((?:in ([^)]*)|([^)]*))\)`)
-		belowThresholdRegex := regexp.MustCompile(`.*below massif's threshold.*`)
+	value, found := utils.ExtractValue(label, line, true)
+	if !found {
+		return 0, fmt.Errorf("snapshot error: %s not found in %s", label, dg.Text())
+	}
 
-		for {
-			nextLine := dg.Scan()
-			// Stop if the delimiter is found, or EOF
-			if atEOF = (!nextLine && dg.Scanner.Err() == nil); atEOF {
-				break
-			}
-			if (nextLine && dg.Text() == delimiter) || atEOF {
-				break
-			} else if !nextLine {
-				return false, fmt.Errorf("snapshot error: %v", dg.Scanner.Err())
-			}
+	n, _, ok := parseDigits(value)
+	if !ok {
+		return 0, fmt.Errorf("snapshot error when converting a string: %s overflows", value)
+	}
 
-			// Check the depth of the current line of the heap tree
-			htLine, depth := utils.LeadingSpaces(dg.Text())
+	return n, nil
+}
 
-			if belowThresholdRegex.MatchString(htLine) {
-				continue
-			}
+// Points the node to its frame in the log frame table. Frames are cached by the bytes of their line, so that the frame of a line seen before is found without allocating.
+// The frame is only built, from bytes of the current token, when missing from the cache
+func (dg *DiggerSite) internFrame(log *outlog.OutLog, node *heaptree.HeapTree, key []byte, build func() heaptree.Frame) {
+	// Frame ids only make sense within the table of a single log
+	if dg.frames == nil || dg.framesOf != log {
+		dg.frames = make(map[string]int)
+		dg.framesOf = log
+	}
 
-			// Root of the Heap Tree
-			if depth == 0 {
-				dg.HTreeCtx.HTreeDepth[0] = ss.HeapTree
-				match := rootRegex.FindStringSubmatch(htLine)
-
-				// Check if the line has the root id, the mem size and the func desc
-				if len(match) < 4 {
-					return false, fmt.Errorf("snapshot error: unsufficient args for the root htree line: %s", htLine)
-				}
-
-				ss.HeapTree.ID, err = strconv.Atoi(match[1])
-				// Handle conversion error
-				if err != nil {
-					return false, fmt.Errorf("snapshot error: conversion error")
-				}
-
-				ss.HeapTree.Address = "root"
-				ss.HeapTree.Memory, err = strconv.Atoi(match[2])
-				// Handle conversion error
-				if err != nil {
-					return false, fmt.Errorf("snapshot error: conversion error")
-				}
-				ss.HeapTree.Func = match[3]
-				ss.HeapTree.FuncFullDesc = match[3]
-			} else {
-				// Depth is strictly positive, a node with depth n belongs to the htree decendence of the last seen leaf of depth n-1
-				newHeapTreeEntry := &heaptree.HeapTree{}
-
-				dg.HTreeCtx.HTreeDepth[depth] = newHeapTreeEntry
-
-				match := descendenceRegex.FindStringSubmatch(htLine)
-
-				// Check if the line has all expected info
-				if len(match) < 6 {
-					return false, fmt.Errorf("snapshot error: unsufficient args for the following htree line: %s, %v", htLine, match)
-				}
-
-				newHeapTreeEntry.ID, err = strconv.Atoi(match[1])
-				// Handle conversion error
-				if err != nil {
-					return false, fmt.Errorf("snapshot error: conversion error")
-				}
-
-				newHeapTreeEntry.Memory, err = strconv.Atoi(match[2])
-				// Handle conversion error
-				if err != nil {
-					return false, fmt.Errorf("snapshot error: conversion error")
-				}
-				newHeapTreeEntry.Address = match[3]
-				newHeapTreeEntry.Func = match[4]
-				newHeapTreeEntry.FuncFullDesc = match[5]
-
-				// Add this node to the list of the descendences of the last seen node of depth -1
-				dg.HTreeCtx.HTreeDepth[depth-1].HeapAllocationLeafs = append(dg.HTreeCtx.HTreeDepth[depth-1].HeapAllocationLeafs, newHeapTreeEntry)
-			}
-		}
-	} else {
-		nextLine := dg.Scan()
-		if (!nextLine && dg.Scanner.Err() == nil) && (nextLine && dg.Text() != delimiter) {
-			return false, fmt.Errorf("snapshot error: expected a delimiter or EOF")
-		}
+	id, ok := dg.frames[string(key)]
+	if !ok {
+		id, _ = log.Frames.Intern(build())
+		dg.frames[string(key)] = id
 	}
-	log.Snapshots = append(log.Snapshots, ss)
-	return atEOF, nil
 
+	node.FrameID = id
+	node.Frame, _ = log.Frames.Frame(id)
 }
@@ -1,12 +1,22 @@
 package digger
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"runtime"
 	"strings"
 	"testing"
+	"unsafe"
 
+	"github.com/MohamTahaB/massif-miner/internal/decompress"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
 	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
 )
 
 func TestInitDiggerSite_OK(t *testing.T) {
@@ -89,7 +99,6 @@ func TestMetaData_AllTimeUnits_OK(t *testing.T) {
 		{"i", outlog.I},
 		{"B", outlog.B},
 		{"ms", outlog.MS},
-		{"auto", outlog.AUTO},
 	}
 
 	var ol outlog.OutLog
@@ -109,6 +118,15 @@ func TestMetaData_AllTimeUnits_OK(t *testing.T) {
 
 }
 
+func TestMetaData_UnresolvedTimeUnit_KO(t *testing.T) {
+
+	// auto is a massif option, massif resolves it before writing the log
+	diggerSite := InitDiggerSite(strings.NewReader("desc: --time-unit=auto\ncmd: ./file/path\ntime_unit: auto\n"))
+	if err := diggerSite.MetaData(&outlog.OutLog{}); !errors.Is(err, outlog.ErrUnresolvedTimeUnit) {
+		t.Fatalf("metadata test error: expected ErrUnresolvedTimeUnit, found %v", err)
+	}
+}
+
 func TestMetaDataOnMassifLog_OK(t *testing.T) {
 
 	// Open the massif.out log in the artifacts
@@ -313,3 +331,394 @@ func TestSnapshotsContent_Regular_OK(t *testing.T) {
 		t.Fatalf("snapshot test error: in snapshot %d, expected the heap tree to be nil", snapshotNo)
 	}
 }
+
+func TestParse_Peak_OK(t *testing.T) {
+
+	// Open the massif.out log in the artifacts
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	ol, err := Parse(file)
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if len(ol.Snapshots) != 60 {
+		t.Fatalf("parse test error: expected 60 snapshots, found %d", len(ol.Snapshots))
+	}
+	if ol.Peak.FlaggedID != 45 || ol.Peak.MaxID != 45 || !ol.Peak.Consistent || ol.Peak.MaxTotal != 165527+3017 {
+		t.Fatalf("parse test error: unexpected peak %+v", ol.Peak)
+	}
+}
+
+func TestParse_SharedFrames_OK(t *testing.T) {
+
+	// Open the massif.out log in the artifacts
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	ol, err := Parse(file)
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+
+	// CAUTION: change in the artifacts should be taken into account here as well, the 8 detailed snapshots share 14 frames
+	if ol.Frames.Len() != 14 {
+		t.Fatalf("parse test error: expected 14 frames, found %d", ol.Frames.Len())
+	}
+
+	// The libstdc++ frame of the first detailed snapshot and of the peak are the same
+	first, peak := ol.Snapshots[4].HeapTree.HeapAllocationLeafs[0], ol.Snapshots[45].HeapTree.HeapAllocationLeafs[1]
+	if first.FrameID == 0 || first.FrameID != peak.FrameID {
+		t.Fatalf("parse test error: expected the frames to be shared, found ids %d and %d", first.FrameID, peak.FrameID)
+	}
+	if unsafe.StringData(first.FuncFullDesc) != unsafe.StringData(peak.FuncFullDesc) {
+		t.Fatal("parse test error: expected the frame strings to be shared")
+	}
+
+	frame, ok := ol.Frames.Frame(peak.FrameID)
+	if !ok || frame.Address != "0x490D939" || frame.FuncFullDesc != "/usr/lib/x86_64-linux-gnu/libstdc++.so.6.0.30" {
+		t.Fatalf("parse test error: unexpected frame %+v", frame)
+	}
+}
+
+func TestParse_Golden_OK(t *testing.T) {
+
+	// Open the massif.out log in the artifacts
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	ol, err := Parse(file)
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+
+	// CAUTION: the golden file holds the encoding of the current schema version, it should be regenerated along with the artifacts
+	golden, err := os.ReadFile("../utils/artifacts/massif.out.golden.json")
+	if err != nil {
+		t.Fatalf("error opening the golden file: %v", err)
+	}
+
+	output, err := json.MarshalIndent(ol, "", "  ")
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+	if !bytes.Equal(append(output, '\n'), golden) {
+		t.Fatal("parse test error: output differs from the golden file")
+	}
+}
+
+func TestDecode_OK(t *testing.T) {
+
+	// Open the massif.out log in the artifacts
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	ol, err := Parse(file)
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+
+	// The version 0 encoding lacks the frame locations
+	withoutLocations, err := Parse(bytes.NewReader(mustReadFile(t, "../utils/artifacts/massif.out.log")))
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+	withoutLocations.Frames = heaptree.FrameTable{}
+	for _, ss := range withoutLocations.Snapshots {
+		ss.HeapTree.Walk(func(node *heaptree.HeapTree, _ []*heaptree.HeapTree) {
+			node.Frame = &heaptree.Frame{Address: node.Address, Func: node.Func, FuncFullDesc: node.FuncFullDesc}
+		})
+		withoutLocations.Frames.InternTree(ss.HeapTree)
+	}
+
+	// CAUTION: the version 0 file holds the output of the former regex based parser, before the schema was versioned
+	expected := map[string]outlog.OutLog{
+		"../utils/artifacts/massif.out.golden.json": ol,
+		"../utils/artifacts/massif.out.v0.json":     withoutLocations,
+	}
+	for path, log := range expected {
+		decoded, err := outlog.Decode(bytes.NewReader(mustReadFile(t, path)))
+		if err != nil {
+			t.Fatalf("parse test error: %s: %v", path, err)
+		}
+		if !reflect.DeepEqual(decoded, log) {
+			t.Fatalf("parse test error: %s decodes to another log than the parsed one", path)
+		}
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error opening %s: %v", path, err)
+	}
+	return content
+}
+
+func TestParseNodeLine_OK(t *testing.T) {
+
+	// Reference regexes of the former parser
+	rootRegex := regexp.MustCompile(`^n(\d+): (\d+) \(([^)]+)\)`)
+	descendenceRegex := regexp.MustCompile(`^n(\d+): (\d+) ([0-9A-Fa-fx]+): (.*?) \((?:in ([^)]*)|([^)]*))\)`)
+
+	lines := []string{
+		"n2: 165527 (heap allocation functions) malloc/new/new[], --alloc-fns, etc.",
+		"n0: 72704 0x490D939: ??? (in /usr/lib/x86_64-linux-gnu/libstdc++.so.6.0.30)",
+		"n1: 72704 0x4006567: _dl_init (dl-init.c:117)",
+		"n0: 10 0x10A3C4: std::vector<int, std::allocator<int> >::_M_realloc_insert(int const&) (new_allocator.h:137)",
+		"n0: 10 0x10A3C4: operator() (lambda) (main.cpp:12)",
+		"n0: 10 0x10A3C4: f (g (in /lib/libx.so)",
+		"n0: 10 0x10A3C4: f (in )",
+		"n0: 10 0x10A3C4:  (in /lib/libx.so)",
+		"n0: 10 0x10A3C4: f (unclosed",
+		"n0: 10 0x10A3C4: f",
+		"n0: 10 zz: f (a.c:1)",
+		"n0: 10 0x1 f (a.c:1)",
+		"n0 10 0x1: f (a.c:1)",
+		"n0: 99999999999999999999999 0x1: f (a.c:1)",
+		"n1: 10 ()",
+		"n1: 10 (f",
+		"",
+	}
+
+	for _, line := range lines {
+		match := descendenceRegex.FindStringSubmatch(line)
+		node, ok := parseNodeLine([]byte(line))
+
+		// Overflowing numbers match the regex, but failed the conversion
+		expected := match != nil && !strings.Contains(line, "99999999999999999999999")
+		if ok != expected {
+			t.Fatalf("tokenizer test error: expected %v for the line %q, found %v", expected, line, ok)
+		}
+		if ok && (fmt.Sprint(node.ID) != match[1] || fmt.Sprint(node.Memory) != match[2] || string(node.Address) != match[3] || string(node.Func) != match[4] || string(node.Desc) != match[5] || string(node.Location) != match[6]) {
+			t.Fatalf("tokenizer test error: unexpected node %+v for the line %q, expected %q", node, line, match)
+		}
+
+		match = rootRegex.FindStringSubmatch(line)
+		id, mem, _, fn, ok := parseRootLine([]byte(line))
+		if ok != (match != nil) {
+			t.Fatalf("tokenizer test error: expected %v for the root line %q, found %v", match != nil, line, ok)
+		}
+		if ok && (fmt.Sprint(id) != match[1] || fmt.Sprint(mem) != match[2] || string(fn) != match[3]) {
+			t.Fatalf("tokenizer test error: unexpected root for the line %q, expected %q", line, match)
+		}
+	}
+}
+
+func TestFetchSnapshot_KO(t *testing.T) {
+
+	header := "desc: --massif-out-file=massif.out\ncmd: ./app\ntime_unit: i\n#-----------\nsnapshot=0\n#-----------\ntime=0\nmem_heap_B=10\nmem_heap_extra_B=0\nmem_stacks_B=0\n"
+
+	for _, snapshot := range []string{
+		// Missing header value
+		"desc: --massif-out-file=massif.out\ncmd: ./app\ntime_unit: i\n#-----------\nsnapshot=\n",
+		// Empty snapshot followed by a stray line
+		header + "heap_tree=empty\nstray\n",
+		// Node with no parent
+		header + "heap_tree=detailed\nn1: 10 (heap allocation functions) malloc/new/new[], --alloc-fns, etc.\n  n0: 10 0x1: f (a.c:1)\n",
+		// Malformed node
+		header + "heap_tree=detailed\nn1: 10 (heap allocation functions) malloc/new/new[], --alloc-fns, etc.\n n0: 10 0x1: f\n",
+		// Detailed snapshot truncated right after the heap tree kind, or followed by the next snapshot, without a root
+		header + "heap_tree=detailed\n",
+		header + "heap_tree=peak\n#-----------\nsnapshot=1\n",
+	} {
+		if _, err := Parse(strings.NewReader(snapshot)); err == nil {
+			t.Fatalf("snapshot test error: expected an error for %q", snapshot)
+		}
+		if _, err := ParseParallel(strings.NewReader(snapshot), DefaultParallelOptions()); err == nil {
+			t.Fatalf("snapshot test error: expected a parallel parsing error for %q", snapshot)
+		}
+	}
+}
+
+// Builds a synthetic massif log out of the artifacts, the snapshots being repeated the given number of times
+func syntheticLog(b *testing.B, times int) []byte {
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		b.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	start := bytes.Index(content, []byte("#-----------\nsnapshot="))
+	if start < 0 || !bytes.HasSuffix(content, []byte("\n")) {
+		b.Fatal("unexpected massif.out log layout")
+	}
+
+	return append(content[:start:start], bytes.Repeat(content[start:], times)...)
+}
+
+func BenchmarkParse(b *testing.B) {
+	// About 18MB of snapshots
+	content := syntheticLog(b, 1000)
+
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(bytes.NewReader(content)); err != nil {
+			b.Fatalf("parse benchmark error: %v", err)
+		}
+	}
+}
+
+// Reports the heap held by a parsed long log, to be compared with and without interning the frames
+func BenchmarkParse_Retained(b *testing.B) {
+	// About 18MB of snapshots, 8000 heap trees
+	content := syntheticLog(b, 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var retained uint64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		log, err := Parse(bytes.NewReader(content))
+		if err != nil {
+			b.Fatalf("parse benchmark error: %v", err)
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained += after.HeapAlloc - before.HeapAlloc
+		runtime.KeepAlive(log)
+	}
+
+	b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+}
+
+func BenchmarkFetchSnapshot_Header(b *testing.B) {
+	header := []byte("snapshot=12\n#-----------\ntime=2279725\nmem_heap_B=72704\nmem_heap_extra_B=8\nmem_stacks_B=0\nheap_tree=empty\n#-----------\n")
+	content := bytes.Repeat(header, 10000)
+
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dg := InitDiggerSite(bytes.NewReader(content))
+		log := outlog.OutLog{Snapshots: make([]snapshot.Snapshot, 0, 10000)}
+		for {
+			atEOF, err := dg.FetchSnapshot(&log)
+			if err != nil {
+				b.Fatalf("snapshot benchmark error: %v", err)
+			}
+			if atEOF || len(log.Snapshots) == 10000 {
+				break
+			}
+		}
+	}
+}
+
+func TestParseFile_Compressed_OK(t *testing.T) {
+
+	// CAUTION: the bzip2 artifact should be regenerated along with the massif.out log
+	compressed, err := ParseFile("../utils/artifacts/massif.out.log.bz2", 0)
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+	plain, err := ParseFile("../utils/artifacts/massif.out.log", 0)
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+
+	expected, _ := json.Marshal(plain)
+	output, _ := json.Marshal(compressed)
+	if !bytes.Equal(output, expected) {
+		t.Fatal("parse test error: compressed output differs from the plain one")
+	}
+
+	if _, err := ParseFile("../utils/artifacts/massif.out.log.bz2", 1<<10); !errors.Is(err, decompress.ErrTooLarge) {
+		t.Fatalf("parse test error: expected ErrTooLarge, found %v", err)
+	}
+}
+
+// Returns a massif log with a single detailed snapshot, whose frame has the given name
+func longFrameLog(name string) string {
+	return "desc: --massif-out-file=massif.out\ncmd: ./app\ntime_unit: i\n" +
+		"#-----------\nsnapshot=0\n#-----------\ntime=0\nmem_heap_B=10\nmem_heap_extra_B=0\nmem_stacks_B=0\nheap_tree=peak\n" +
+		"n1: 10 (heap allocation functions) malloc/new/new[], --alloc-fns, etc.\n" +
+		" n0: 10 0x10A3C4: " + name + " (main.cpp:12)\n"
+}
+
+func TestParse_LongLine_OK(t *testing.T) {
+
+	// A templated C++ frame of about 8MB, far beyond the 64KB default of bufio.Scanner
+	name := "std::vector<" + strings.Repeat("std::map<int, std::vector<std::string>>, ", 200000) + "int>::push_back(int const&)"
+	content := longFrameLog(name)
+
+	ol, err := Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+	if frame := ol.Snapshots[0].HeapTree.HeapAllocationLeafs[0]; frame.Func != name || frame.Memory != 10 {
+		t.Fatalf("parse test error: unexpected frame of %d bytes with a %d bytes name", frame.Memory, len(frame.Func))
+	}
+
+	parallel, err := ParseParallel(strings.NewReader(content), ParallelOptions{ChunkSize: 1 << 10})
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+	if parallel.Snapshots[0].HeapTree.HeapAllocationLeafs[0].Func != name {
+		t.Fatal("parse test error: unexpected frame name with the parallel parsing")
+	}
+}
+
+func TestParse_LongLine_KO(t *testing.T) {
+
+	content := longFrameLog(strings.Repeat("f", 2<<20))
+
+	if _, err := ParseMaxLine(strings.NewReader(content), 1<<20); !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("parse test error: expected ErrLineTooLong, found %v", err)
+	}
+	if _, err := ParseParallel(strings.NewReader(content), ParallelOptions{MaxLineBytes: 1 << 20}); !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("parse test error: expected ErrLineTooLong with the parallel parsing, found %v", err)
+	}
+
+	// The maximum is inclusive
+	longest := 0
+	for _, line := range strings.Split(longFrameLog("f"), "\n") {
+		longest = max(longest, len(line))
+	}
+	if _, err := ParseMaxLine(strings.NewReader(longFrameLog("f")), longest); err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+	if _, err := ParseMaxLine(strings.NewReader(longFrameLog("f")), longest-1); !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("parse test error: expected ErrLineTooLong, found %v", err)
+	}
+}
+
+func TestParseMaxLine_NotPositive_KO(t *testing.T) {
+
+	// A maximum that is not positive falls back to DefaultMaxLineBytes, instead of panicking or rejecting every line
+	name := strings.Repeat("f", 1<<20)
+	for _, maxLineBytes := range []int{0, -1} {
+		ol, err := ParseMaxLine(strings.NewReader(longFrameLog(name)), maxLineBytes)
+		if err != nil {
+			t.Fatalf("parse test error: maximum %d: %v", maxLineBytes, err)
+		}
+		if ol.Snapshots[0].HeapTree.HeapAllocationLeafs[0].Func != name {
+			t.Fatalf("parse test error: maximum %d: unexpected frame name", maxLineBytes)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package digger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Default size of the chunks handed over to the parsing workers
+const DefaultChunkSize = 4 << 20
+
+// Beginning of every snapshot of a massif log, chunks are split right before it
+var snapshotBoundary = []byte(delimiter + "\nsnapshot=")
+
+// Define the tuning of the parallel parsing
+type ParallelOptions struct {
+	// Number of parsing workers, the number of usable CPUs when not positive
+	Workers int
+	// Minimum size of a chunk in bytes, a chunk holding whole snapshots only
+	ChunkSize int
+	// Maximum length of a line, DefaultMaxLineBytes when not positive
+	MaxLineBytes int
+}
+
+// Returns the default parallel options: one worker per usable CPU, chunks of 4MB and lines of up to DefaultMaxLineBytes
+func DefaultParallelOptions() ParallelOptions {
+	return ParallelOptions{Workers: runtime.GOMAXPROCS(0), ChunkSize: DefaultChunkSize, MaxLineBytes: DefaultMaxLineBytes}
+}
+
+// Define a chunk of snapshots of a log, along with its position among the chunks
+type chunk struct {
+	index int
+	data  []byte
+}
+
+// Define the snapshots parsed out of a chunk
+type chunkResult struct {
+	index int
+	log   outlog.OutLog
+	err   error
+}
+
+// Parses a whole massif log like Parse, the snapshots being parsed concurrently by a pool of workers.
+// The input is split into chunks of whole snapshots, each parsed with its own digger site and frame table, and the snapshots are gathered back in order.
+// The chunk tables are merged into the log table in the order of the input, and the nodes remapped to it, so that the output is the same as the sequential one
+func ParseParallel(r io.Reader, opts ParallelOptions) (outlog.OutLog, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.MaxLineBytes <= 0 {
+		opts.MaxLineBytes = DefaultMaxLineBytes
+	}
+
+	// The reader buffer should at least hold a snapshot boundary to look it up
+	br := bufio.NewReaderSize(r, max(opts.ChunkSize, 2*len(snapshotBoundary)))
+	log := outlog.OutLog{}
+
+	// The metadata runs up to the first snapshot, the whole log being parsed sequentially when there is none
+	header, err := readUntilBoundary(br)
+	if err != nil {
+		return log, fmt.Errorf("parse error: %v", err)
+	}
+	if _, err := br.Peek(1); err == io.EOF {
+		return ParseMaxLine(bytes.NewReader(header), opts.MaxLineBytes)
+	}
+	dg := InitDiggerSiteMaxLine(io.MultiReader(bytes.NewReader(header), bytes.NewReader([]byte(delimiter+"\n"))), opts.MaxLineBytes)
+	if err := dg.MetaData(&log); err != nil {
+		return log, dg.readError(err)
+	}
+
+	// Chunk buffers are handed back once parsed, the parsed snapshots holding no reference to them
+	buffers := sync.Pool{New: func() any { return make([]byte, 0, opts.ChunkSize+opts.ChunkSize/4) }}
+
+	chunks := make(chan chunk)
+	results := make(chan chunkResult)
+	done := make(chan struct{})
+
+	var workers sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for c := range chunks {
+				part, err := parseChunk(c.data, opts.MaxLineBytes)
+				buffers.Put(c.data[:0])
+				results <- chunkResult{index: c.index, log: part, err: err}
+			}
+		}()
+	}
+
+	// Split the input in the background, until EOF or until the first failure
+	var readErr error
+	go func() {
+		defer close(chunks)
+		for index := 0; ; index++ {
+			data, err := readChunk(br, buffers.Get().([]byte), opts.ChunkSize)
+			if len(data) > 0 {
+				select {
+				case chunks <- chunk{index: index, data: data}:
+				case <-done:
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr = err
+				return
+			}
+		}
+	}()
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	// Gather the chunks by index, keeping the failure of the earliest one
+	var parts []outlog.OutLog
+	var failed *chunkResult
+	for result := range results {
+		if result.err != nil {
+			if failed == nil {
+				close(done)
+			}
+			if failed == nil || result.index < failed.index {
+				failed = &result
+			}
+			continue
+		}
+		for len(parts) <= result.index {
+			parts = append(parts, outlog.OutLog{})
+		}
+		parts[result.index] = result.log
+	}
+
+	if failed != nil {
+		return log, failed.err
+	}
+	if readErr != nil {
+		return log, fmt.Errorf("parse error: %v", readErr)
+	}
+
+	count := 0
+	for _, part := range parts {
+		count += len(part.Snapshots)
+		log.StacksProfiled = log.StacksProfiled || part.StacksProfiled
+	}
+	log.Snapshots = make([]snapshot.Snapshot, 0, count)
+	for _, part := range parts {
+		log.Snapshots = append(log.Snapshots, part.Snapshots...)
+	}
+	for i := range log.Snapshots {
+		log.Snapshots[i].StacksProfiled = log.StacksProfiled
+	}
+
+	mergeFrames(&log, parts, opts.Workers)
+	log.DetectPeak()
+	return log, nil
+}
+
+// Merges the frame tables of the chunks into the log table, in order, and points the nodes of the chunks to the log frames.
+// Only the distinct frames of every chunk are interned again, the nodes being remapped by id concurrently
+func mergeFrames(log *outlog.OutLog, parts []outlog.OutLog, workers int) {
+	remaps := make([][]*heaptree.Frame, len(parts))
+	ids := make([][]int, len(parts))
+	for i := range parts {
+		remaps[i] = make([]*heaptree.Frame, parts[i].Frames.Len()+1)
+		ids[i] = make([]int, parts[i].Frames.Len()+1)
+		for id := 1; id <= parts[i].Frames.Len(); id++ {
+			frame, _ := parts[i].Frames.Frame(id)
+			ids[i][id], remaps[i][id] = log.Frames.Intern(*frame)
+		}
+	}
+
+	indexes := make(chan int)
+	var remappers sync.WaitGroup
+	for w := 0; w < min(workers, len(parts)); w++ {
+		remappers.Add(1)
+		go func() {
+			defer remappers.Done()
+			for i := range indexes {
+				for _, ss := range parts[i].Snapshots {
+					if ss.HeapTree != nil {
+						remapFrames(ss.HeapTree, ids[i], remaps[i])
+					}
+				}
+			}
+		}()
+	}
+	for i := range parts {
+		indexes <- i
+	}
+	close(indexes)
+	remappers.Wait()
+}
+
+// Points the nodes of the tree to the frames of the log table, given the log ids and frames of the chunk frame ids
+func remapFrames(node *heaptree.HeapTree, ids []int, frames []*heaptree.Frame) {
+	node.FrameID, node.Frame = ids[node.FrameID], frames[node.FrameID]
+	for _, leaf := range node.HeapAllocationLeafs {
+		remapFrames(leaf, ids, frames)
+	}
+}
+
+// Parses the snapshots of a chunk into a log of its own
+func parseChunk(data []byte, maxLineBytes int) (outlog.OutLog, error) {
+	part := outlog.OutLog{}
+	dg := InitDiggerSiteMaxLine(bytes.NewReader(data), maxLineBytes)
+	err := dg.fetchSnapshots(&part)
+	return part, err
+}
+
+// Reads the input up to the next snapshot boundary, or up to EOF, the boundary being left in the reader
+func readUntilBoundary(br *bufio.Reader) ([]byte, error) {
+	var data []byte
+	// Whether the last read stopped in the middle of a line longer than the reader buffer
+	partial := false
+	for {
+		if !partial {
+			if next, _ := br.Peek(len(snapshotBoundary)); bytes.Equal(next, snapshotBoundary) {
+				return data, nil
+			}
+		}
+
+		line, err := br.ReadSlice('\n')
+		data = append(data, line...)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return data, err
+		}
+		partial = err == bufio.ErrBufferFull
+	}
+}
+
+// Reads a chunk of at least the given size into the buffer, or up to EOF, ending right before a snapshot boundary.
+// The snapshot boundary the chunk ends at is kept in the reader for the next chunk
+func readChunk(br *bufio.Reader, data []byte, size int) ([]byte, error) {
+	// The chunk starts with the boundary of its first snapshot, consume it so that it is not mistaken for the end
+	boundary, err := br.Peek(len(snapshotBoundary))
+	if err == nil && bytes.Equal(boundary, snapshotBoundary) {
+		data = append(data, boundary...)
+		if _, err := br.Discard(len(boundary)); err != nil {
+			return data, err
+		}
+	}
+
+	// Read the bulk of the chunk at once, then complete its last line, the boundary lookup starting at the beginning of a line
+	if len(data) < size {
+		start := len(data)
+		data = slices.Grow(data, size-start)[:size]
+		n, err := io.ReadFull(br, data[start:])
+		data = data[:start+n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return data, io.EOF
+		}
+		if err != nil {
+			return data, err
+		}
+	}
+	for len(data) > 0 && data[len(data)-1] != '\n' {
+		line, err := br.ReadSlice('\n')
+		data = append(data, line...)
+		if err != nil && err != bufio.ErrBufferFull {
+			return data, err
+		}
+	}
+
+	rest, err := readUntilBoundary(br)
+	return append(data, rest...), err
+}
@@ -0,0 +1,108 @@
+package digger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+)
+
+func TestParseParallel_OK(t *testing.T) {
+
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	ol, err := Parse(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("parse test error: %v", err)
+	}
+	expected, _ := json.Marshal(ol)
+
+	// From a chunk per snapshot to a single chunk
+	for _, opts := range []ParallelOptions{
+		{Workers: 1, ChunkSize: 16},
+		{Workers: 4, ChunkSize: 16},
+		{Workers: 3, ChunkSize: 1024},
+		{Workers: 8, ChunkSize: 1 << 20},
+		{},
+	} {
+		parallel, err := ParseParallel(bytes.NewReader(content), opts)
+		if err != nil {
+			t.Fatalf("parallel test error with %+v: %v", opts, err)
+		}
+
+		// CAUTION: change in the artifacts should be taken into account here as well
+		if len(parallel.Snapshots) != 60 || parallel.Peak.FlaggedID != 45 {
+			t.Fatalf("parallel test error with %+v: unexpected snapshots count %d or peak %+v", opts, len(parallel.Snapshots), parallel.Peak)
+		}
+
+		output, _ := json.Marshal(parallel)
+		if !bytes.Equal(output, expected) {
+			t.Fatalf("parallel test error with %+v: output differs from the sequential one", opts)
+		}
+	}
+}
+
+func TestParseParallel_NoSnapshots_OK(t *testing.T) {
+
+	ol, err := ParseParallel(strings.NewReader("desc: --stacks=yes\ncmd: ./app\ntime_unit: ms\n"), DefaultParallelOptions())
+	if err != nil {
+		t.Fatalf("parallel test error: %v", err)
+	}
+	if ol.Cmd != "./app" || !ol.StacksProfiled || len(ol.Snapshots) != 0 {
+		t.Fatalf("parallel test error: unexpected log %+v", ol)
+	}
+}
+
+func TestParseParallel_KO(t *testing.T) {
+
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	// Break the metadata, then a snapshot in the middle of the log
+	for _, broken := range [][]byte{
+		bytes.Replace(content, []byte("cmd: "), []byte("command: "), 1),
+		bytes.Replace(content, []byte("mem_heap_B=165527"), []byte("mem_heap_B=?"), 1),
+	} {
+		if _, err := ParseParallel(bytes.NewReader(broken), ParallelOptions{Workers: 4, ChunkSize: 16}); err == nil {
+			t.Fatal("parallel test error: expected an error")
+		}
+	}
+}
+
+// Compares the parallel parsing with the sequential one, e.g. with -cpu 1,4,8. With a single CPU the parallel parsing costs the chunking and remapping,
+// the workers outpacing Parse as CPUs are added
+func BenchmarkParseParallel(b *testing.B) {
+	// About 18MB of snapshots
+	content := syntheticLog(b, 1000)
+
+	parsers := []struct {
+		name  string
+		parse func(r io.Reader) (outlog.OutLog, error)
+	}{
+		{"sequential", Parse},
+		{"parallel", func(r io.Reader) (outlog.OutLog, error) { return ParseParallel(r, DefaultParallelOptions()) }},
+	}
+
+	for _, parser := range parsers {
+		b.Run(parser.name, func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.parse(bytes.NewReader(content)); err != nil {
+					b.Fatalf("parse benchmark error: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package digger
+
+import (
+	"bytes"
+	"math"
+)
+
+// Define the heap tree line of a frame, e.g. "n1: 1024 0x4006567: _dl_init (dl-init.c:117)".
+// Byte fields point into the scanned line, and are only valid until the next scan
+type nodeLine struct {
+	ID     int
+	Memory int
+	// Bytes of the frame, from the address to the closing parenthesis, used as the frame cache key
+	Frame   []byte
+	Address []byte
+	Func    []byte
+	// Object of the frame, empty for "(file:line)" frames
+	Desc []byte
+	// Source file and line of the frame, empty for "(in object)" frames
+	Location []byte
+}
+
+var belowThreshold = []byte("below massif's threshold")
+
+// Checks whether the heap tree line stands for the allocations below the massif threshold, e.g. "n0: 4096 in 12 places, all below massif's threshold (1.00%)"
+func isBelowThreshold(line []byte) bool {
+	return bytes.Contains(line, belowThreshold)
+}
+
+// Splits a heap tree line into its content and its depth, i.e. the number of leading spaces
+func splitDepth(line []byte) ([]byte, int) {
+	depth := 0
+	for depth < len(line) && line[depth] == ' ' {
+		depth++
+	}
+	return line[depth:], depth
+}
+
+// Parses a run of ascii digits at the beginning of the input, and returns the value along with the remaining bytes.
+// Returns false when there are no digits, or when the value overflows
+func parseDigits(b []byte) (int, []byte, bool) {
+	n, i := 0, 0
+	for ; i < len(b) && b[i] >= '0' && b[i] <= '9'; i++ {
+		digit := int(b[i] - '0')
+		// Only values close to the maximum need the exact overflow check
+		if n > (math.MaxInt-9)/10 && n > (math.MaxInt-digit)/10 {
+			return 0, b, false
+		}
+		n = n*10 + digit
+	}
+	return n, b[i:], i > 0
+}
+
+// Parses the "nID: MEM " prefix shared by every heap tree line, and returns the remaining bytes
+func parseNodePrefix(line []byte) (int, int, []byte, bool) {
+	if len(line) == 0 || line[0] != 'n' {
+		return 0, 0, nil, false
+	}
+
+	id, rest, ok := parseDigits(line[1:])
+	if !ok || len(rest) < 2 || rest[0] != ':' || rest[1] != ' ' {
+		return 0, 0, nil, false
+	}
+
+	mem, rest, ok := parseDigits(rest[2:])
+	if !ok || len(rest) == 0 || rest[0] != ' ' {
+		return 0, 0, nil, false
+	}
+
+	return id, mem, rest[1:], true
+}
+
+// Parses the root line of a heap tree, e.g. "n2: 1024 (heap allocation functions) malloc/new/new[], --alloc-fns, etc.".
+// Returns the frame bytes, parentheses included, and the function, i.e. their content
+func parseRootLine(line []byte) (id, mem int, frame, fn []byte, ok bool) {
+	id, mem, rest, ok := parseNodePrefix(line)
+	if !ok || len(rest) == 0 || rest[0] != '(' {
+		return 0, 0, nil, nil, false
+	}
+
+	end := bytes.IndexByte(rest, ')')
+	if end < 2 {
+		return 0, 0, nil, nil, false
+	}
+
+	return id, mem, rest[:end+1], rest[1:end], true
+}
+
+// Parses a descendant line of a heap tree, e.g. "n0: 1024 0x4006567: _dl_init (dl-init.c:117)" or "n0: 1024 0x401178: main (in /bin/app)".
+// The function runs up to the first " (" followed by a closing parenthesis, the object being the parenthesized content when it starts with "in "
+func parseNodeLine(line []byte) (nodeLine, bool) {
+	var node nodeLine
+	var rest []byte
+	var ok bool
+
+	if node.ID, node.Memory, rest, ok = parseNodePrefix(line); !ok {
+		return node, false
+	}
+
+	// The address is a run of hexadecimal digits and 'x', followed by ": "
+	addrEnd := 0
+	for addrEnd < len(rest) && isAddressByte(rest[addrEnd]) {
+		addrEnd++
+	}
+	if addrEnd == 0 || len(rest) < addrEnd+2 || rest[addrEnd] != ':' || rest[addrEnd+1] != ' ' {
+		return node, false
+	}
+	node.Address = rest[:addrEnd]
+
+	funcStart := addrEnd + 2
+	open := bytes.Index(rest[funcStart:], []byte(" ("))
+	if open < 0 {
+		return node, false
+	}
+	open += funcStart
+
+	// Any closing parenthesis after the first opening one ends the frame, since the function may not be followed by another " ("
+	closing := bytes.IndexByte(rest[open+2:], ')')
+	if closing < 0 {
+		return node, false
+	}
+	closing += open + 2
+
+	node.Func = rest[funcStart:open]
+	if desc, isObject := bytes.CutPrefix(rest[open+2:closing], []byte("in ")); isObject {
+		node.Desc = desc
+	} else {
+		node.Location = desc
+	}
+	node.Frame = rest[:closing+1]
+
+	return node, true
+}
+
+func isAddressByte(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') || c == 'x'
+}
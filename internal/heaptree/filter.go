@@ -0,0 +1,188 @@
+package heaptree
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Define pprof-like filters over the call paths of a heap tree.
+// Nil regexes and zero thresholds are not applied
+type Filter struct {
+	// Keeps only the paths going through a matching frame
+	Focus *regexp.Regexp
+	// Drops the paths going through a matching frame
+	Ignore *regexp.Regexp
+	// Elides the matching frames, their bytes are kept by the remaining ones
+	Hide *regexp.Regexp
+	// Prunes the nodes holding less bytes than the threshold, their bytes are kept by their parent
+	PruneBytes int
+	// Prunes the nodes holding less than the given percentage of the filtered tree
+	PrunePercent float64
+}
+
+// Builds a filter from its textual form, e.g. from query parameters. Empty strings are not applied.
+// The prune threshold is either a number of bytes ("1024") or a percentage of the tree ("1.5%")
+func ParseFilter(focus, ignore, hide, prune string) (Filter, error) {
+	var filter Filter
+	var err error
+
+	if filter.Focus, err = compileOptional(focus); err != nil {
+		return filter, fmt.Errorf("filter error: invalid focus: %v", err)
+	}
+	if filter.Ignore, err = compileOptional(ignore); err != nil {
+		return filter, fmt.Errorf("filter error: invalid ignore: %v", err)
+	}
+	if filter.Hide, err = compileOptional(hide); err != nil {
+		return filter, fmt.Errorf("filter error: invalid hide: %v", err)
+	}
+
+	if prune == "" {
+		return filter, nil
+	}
+	if percent, isPercent := strings.CutSuffix(prune, "%"); isPercent {
+		if filter.PrunePercent, err = strconv.ParseFloat(percent, 64); err != nil || filter.PrunePercent < 0 {
+			return filter, fmt.Errorf("filter error: invalid prune percentage %s", prune)
+		}
+		return filter, nil
+	}
+	if filter.PruneBytes, err = strconv.Atoi(prune); err != nil || filter.PruneBytes < 0 {
+		return filter, fmt.Errorf("filter error: invalid prune threshold %s", prune)
+	}
+
+	return filter, nil
+}
+
+// Applies the filter to the heap tree, and returns the resulting tree.
+// Every node memory is recomputed from the paths that are kept, the root holding their total. The input tree is left untouched
+func (f Filter) Apply(tree *HeapTree) *HeapTree {
+	if tree == nil {
+		return nil
+	}
+
+	filtered := &HeapTree{FrameID: tree.FrameID, Frame: tree.Frame}
+	children := make(map[*HeapTree]map[frameKey]*HeapTree)
+
+	tree.paths(func(frames []*HeapTree, own int) {
+		if f.Focus != nil && !anyMatch(frames, f.Focus) {
+			return
+		}
+		if f.Ignore != nil && anyMatch(frames, f.Ignore) {
+			return
+		}
+
+		kept := frames
+		if f.Hide != nil {
+			kept = make([]*HeapTree, 0, len(frames))
+			for _, frame := range frames {
+				if !frame.matches(f.Hide) {
+					kept = append(kept, frame)
+				}
+			}
+		}
+
+		filtered.Memory += own
+		current := filtered
+		for _, frame := range kept {
+			current = current.child(children, frame.FrameID, frame.Frame)
+			current.Memory += own
+		}
+	})
+
+	threshold := f.PruneBytes
+	if percent := int(f.PrunePercent * float64(filtered.Memory) / 100); percent > threshold {
+		threshold = percent
+	}
+
+	filtered.Walk(func(node *HeapTree, _ []*HeapTree) {
+		if threshold > 0 {
+			kept := node.HeapAllocationLeafs[:0]
+			for _, leaf := range node.HeapAllocationLeafs {
+				if leaf.Memory >= threshold {
+					kept = append(kept, leaf)
+				}
+			}
+			node.HeapAllocationLeafs = kept
+		}
+		node.sortLeafs()
+	})
+
+	return filtered
+}
+
+// Calls visit for every call path of the heap tree ending with bytes of its own, i.e. bytes not accounted for by the children of its last node.
+// Frames are given from the direct caller of the allocation function outwards, bytes of the root itself are given with no frames
+func (ht *HeapTree) paths(visit func(frames []*HeapTree, own int)) {
+	ht.Walk(func(node *HeapTree, ancestors []*HeapTree) {
+		own := node.Memory
+		for _, leaf := range node.HeapAllocationLeafs {
+			own -= leaf.Memory
+		}
+		if own <= 0 {
+			return
+		}
+
+		if len(ancestors) == 0 {
+			visit(nil, own)
+			return
+		}
+
+		frames := make([]*HeapTree, 0, len(ancestors))
+		frames = append(frames, ancestors[1:]...)
+		visit(append(frames, node), own)
+	})
+}
+
+// Identifies the frame of a node when matching call paths: its id when interned, and its frame otherwise, so that no key string is built
+type frameKey struct {
+	id    int
+	frame Frame
+}
+
+func keyOf(id int, frame *Frame) frameKey {
+	if id != 0 {
+		return frameKey{id: id}
+	}
+	return frameKey{frame: *frame}
+}
+
+// Returns the leaf of the node standing for the given frame, creating it with no memory if needed.
+// Leafs are looked up in the children index, keyed by frame id, so that the frames should be of a single table
+func (ht *HeapTree) child(children map[*HeapTree]map[frameKey]*HeapTree, id int, frame *Frame) *HeapTree {
+	if children[ht] == nil {
+		children[ht] = make(map[frameKey]*HeapTree)
+	}
+
+	key := keyOf(id, frame)
+	leaf, ok := children[ht][key]
+	if !ok {
+		leaf = &HeapTree{FrameID: id, Frame: frame}
+		children[ht][key] = leaf
+		ht.HeapAllocationLeafs = append(ht.HeapAllocationLeafs, leaf)
+	}
+
+	return leaf
+}
+
+// Checks whether the regex matches the node address, function, object or source location
+func (ht *HeapTree) matches(re *regexp.Regexp) bool {
+	return re.MatchString(ht.Func) || re.MatchString(ht.Address) || (ht.FuncFullDesc != "" && re.MatchString(ht.FuncFullDesc)) ||
+		(ht.Location != "" && re.MatchString(ht.Location))
+}
+
+func anyMatch(frames []*HeapTree, re *regexp.Regexp) bool {
+	for _, frame := range frames {
+		if frame.matches(re) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
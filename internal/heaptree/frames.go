@@ -0,0 +1,104 @@
+package heaptree
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Define a frame of a heap tree, e.g. "0x4006567: _dl_init (dl-init.c:117)"
+type Frame struct {
+	Address      string `json:"address"`
+	Func         string `json:"func"`
+	FuncFullDesc string `json:"funcFullDesc"`
+	// Source file and line of the frame, e.g. "dl-init.c:117", empty when massif only knows its object
+	Location string `json:"location"`
+}
+
+// Define the table of the distinct frames of a log. Heap tree nodes reference its frames by id, and point to them.
+// Ids start at 1, 0 standing for a node that is not in any table
+type FrameTable struct {
+	frames []*Frame
+	index  map[Frame]int
+}
+
+// Returns the id of the frame in the table, adding it if needed, along with the table frame, to be shared by the nodes.
+// The frame strings are copied when added, so that they do not hold on to a larger string, e.g. the line they were parsed from
+func (ft *FrameTable) Intern(frame Frame) (int, *Frame) {
+	if ft.index == nil {
+		ft.reindex()
+	}
+
+	if id, ok := ft.index[frame]; ok {
+		return id, ft.frames[id-1]
+	}
+
+	interned := &Frame{
+		Address:      strings.Clone(frame.Address),
+		Func:         strings.Clone(frame.Func),
+		FuncFullDesc: strings.Clone(frame.FuncFullDesc),
+		Location:     strings.Clone(frame.Location),
+	}
+	ft.frames = append(ft.frames, interned)
+	ft.index[*interned] = len(ft.frames)
+
+	return len(ft.frames), interned
+}
+
+// Interns the frame of the node, and points the node to the table frame
+func (ft *FrameTable) InternNode(node *HeapTree) {
+	node.FrameID, node.Frame = ft.Intern(*node.Frame)
+}
+
+// Interns the frames of every node of the tree, e.g. for a tree built out of several logs
+func (ft *FrameTable) InternTree(tree *HeapTree) {
+	tree.Walk(func(node *HeapTree, _ []*HeapTree) {
+		ft.InternNode(node)
+	})
+}
+
+// Points the interned nodes of the tree to the table frames, e.g. once decoded, so that they share them.
+// Nodes whose frame differs from the table one are left untouched
+func (ft *FrameTable) Share(tree *HeapTree) {
+	tree.Walk(func(node *HeapTree, _ []*HeapTree) {
+		if frame, ok := ft.Frame(node.FrameID); ok && node.Frame != nil && *frame == *node.Frame {
+			node.Frame = frame
+		}
+	})
+}
+
+// Returns the frame with the given id, and whether it is in the table
+func (ft *FrameTable) Frame(id int) (*Frame, bool) {
+	if id < 1 || id > len(ft.frames) {
+		return nil, false
+	}
+	return ft.frames[id-1], true
+}
+
+// Returns the number of frames in the table
+func (ft *FrameTable) Len() int {
+	return len(ft.frames)
+}
+
+// Encodes the table as the array of its frames, the frame with id i at index i-1
+func (ft FrameTable) MarshalJSON() ([]byte, error) {
+	if ft.frames == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(ft.frames)
+}
+
+// Decodes the table from the array of its frames
+func (ft *FrameTable) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &ft.frames); err != nil {
+		return err
+	}
+	ft.reindex()
+	return nil
+}
+
+func (ft *FrameTable) reindex() {
+	ft.index = make(map[Frame]int, len(ft.frames))
+	for i, frame := range ft.frames {
+		ft.index[*frame] = i + 1
+	}
+}
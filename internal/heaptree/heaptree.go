@@ -2,14 +2,33 @@ package heaptree
 
 // Define the heap tree struct to be implemented in the detailed snapshots
 type HeapTree struct {
-	ID                  int
-	Memory              int
-	Address             string
-	Func                string
-	FuncFullDesc        string
-	HeapAllocationLeafs []*HeapTree
+	ID     int `json:"id"`
+	Memory int `json:"memory"`
+	// Id of the node frame in the frame table of its log, 0 when not interned
+	FrameID int `json:"frameId"`
+	// Frame of the node, never nil. Interned nodes point to the frame of the table, and hold no strings of their own
+	*Frame
+	HeapAllocationLeafs []*HeapTree `json:"children,omitempty"`
 }
 
-type HeapTreeDepthCtx struct {
-	HTreeDepth map[int]*HeapTree
+// Returns a textual representation of the frame the node stands for, of the form "address: func"
+func (ht *HeapTree) FrameName() string {
+	return ht.Address + ": " + ht.Func
+}
+
+// Walks the heap tree depth first, calling visit on every node along with its ancestors (root first, node excluded).
+// The ancestors slice is reused between calls, and should be copied if retained
+func (ht *HeapTree) Walk(visit func(node *HeapTree, ancestors []*HeapTree)) {
+	if ht == nil {
+		return
+	}
+	ht.walk(nil, visit)
+}
+
+func (ht *HeapTree) walk(ancestors []*HeapTree, visit func(node *HeapTree, ancestors []*HeapTree)) {
+	visit(ht, ancestors)
+	ancestors = append(ancestors, ht)
+	for _, leaf := range ht.HeapAllocationLeafs {
+		leaf.walk(ancestors, visit)
+	}
 }
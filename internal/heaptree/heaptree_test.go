@@ -0,0 +1,240 @@
+package heaptree
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Builds the heap tree of a program where both alloc() and main allocate, through different paths
+func sampleTree() *HeapTree {
+	return &HeapTree{
+		ID: 2, Memory: 110, Frame: &Frame{Address: "root", Func: "heap allocation functions"},
+		HeapAllocationLeafs: []*HeapTree{
+			{ID: 2, Memory: 70, Frame: &Frame{Address: "0x1", Func: "alloc()"}, HeapAllocationLeafs: []*HeapTree{
+				{ID: 1, Memory: 50, Frame: &Frame{Address: "0x2", Func: "worker()"}, HeapAllocationLeafs: []*HeapTree{
+					{ID: 0, Memory: 50, Frame: &Frame{Address: "0x3", Func: "main"}},
+				}},
+				{ID: 0, Memory: 20, Frame: &Frame{Address: "0x3", Func: "main"}},
+			}},
+			{ID: 1, Memory: 30, Frame: &Frame{Address: "0x4", Func: "init()"}, HeapAllocationLeafs: []*HeapTree{
+				{ID: 0, Memory: 30, Frame: &Frame{Address: "0x3", Func: "main"}},
+			}},
+		},
+	}
+}
+
+func TestWalk_OK(t *testing.T) {
+	nodes, maxDepth := 0, 0
+	sampleTree().Walk(func(node *HeapTree, ancestors []*HeapTree) {
+		nodes++
+		if len(ancestors) > maxDepth {
+			maxDepth = len(ancestors)
+		}
+	})
+
+	if nodes != 7 || maxDepth != 3 {
+		t.Fatalf("heaptree test error: expected 7 nodes and a max depth of 3, found %d and %d", nodes, maxDepth)
+	}
+}
+
+func TestInvert_OK(t *testing.T) {
+	tree := sampleTree()
+	inverted := Invert(tree)
+
+	// The root keeps the total, bytes below the threshold included
+	if inverted.Memory != 110 || inverted.Address != "root" {
+		t.Fatalf("heaptree test error: unexpected inverted root %+v", inverted)
+	}
+
+	// main is the only outermost frame, and holds everything above the threshold
+	if len(inverted.HeapAllocationLeafs) != 1 {
+		t.Fatalf("heaptree test error: expected a single outermost frame, found %d", len(inverted.HeapAllocationLeafs))
+	}
+	main := inverted.HeapAllocationLeafs[0]
+	if main.Func != "main" || main.Memory != 100 || main.ID != 3 {
+		t.Fatalf("heaptree test error: unexpected main node %+v", main)
+	}
+
+	// main callees are sorted by decreasing memory
+	expected := []struct {
+		fn     string
+		memory int
+	}{{"worker()", 50}, {"init()", 30}, {"alloc()", 20}}
+	for i, callee := range main.HeapAllocationLeafs {
+		if callee.Func != expected[i].fn || callee.Memory != expected[i].memory {
+			t.Fatalf("heaptree test error: expected %v at %d, found %+v", expected[i], i, callee)
+		}
+	}
+
+	// worker() leads down to alloc()
+	worker := main.HeapAllocationLeafs[0]
+	if len(worker.HeapAllocationLeafs) != 1 || worker.HeapAllocationLeafs[0].Func != "alloc()" || worker.HeapAllocationLeafs[0].Memory != 50 {
+		t.Fatalf("heaptree test error: unexpected worker() callees %+v", worker.HeapAllocationLeafs)
+	}
+
+	// The input tree is left untouched
+	if tree.HeapAllocationLeafs[0].Func != "alloc()" || tree.HeapAllocationLeafs[0].Memory != 70 {
+		t.Fatal("heaptree test error: the input tree was modified")
+	}
+
+	if Invert(nil) != nil {
+		t.Fatal("heaptree test error: expected the inverted nil tree to be nil")
+	}
+}
+
+func TestMerge_OK(t *testing.T) {
+	// Trees of a single log are matched by frame id
+	var ft FrameTable
+	first, second := sampleTree(), sampleTree()
+	ft.InternTree(first)
+	ft.InternTree(second)
+
+	merged := Merge(first, nil, second)
+	if merged.Memory != 220 || len(merged.HeapAllocationLeafs) != 2 || merged.HeapAllocationLeafs[0].Memory != 140 {
+		t.Fatalf("heaptree test error: unexpected merged tree %+v", merged)
+	}
+	if inverted := Invert(first); len(inverted.HeapAllocationLeafs) != 1 || inverted.HeapAllocationLeafs[0].Memory != 100 {
+		t.Fatal("heaptree test error: the paths of the interned tree are not matched by frame id")
+	}
+
+	// Trees of different logs have frame ids of their own tables, their frames are matched in a common table
+	var other FrameTable
+	other.Intern(Frame{Address: "0x9", Func: "unrelated()"})
+	third := sampleTree()
+	other.InternTree(third)
+	if third.FrameID == first.FrameID {
+		t.Fatal("heaptree test error: the frame ids of both tables should differ")
+	}
+
+	var common FrameTable
+	merged = common.Merge(first, third)
+	if merged.Memory != 220 || len(merged.HeapAllocationLeafs) != 2 || merged.HeapAllocationLeafs[0].Memory != 140 || common.Len() != 5 {
+		t.Fatalf("heaptree test error: unexpected merged tree of different logs %+v, %d frames", merged, common.Len())
+	}
+	merged.Walk(func(node *HeapTree, _ []*HeapTree) {
+		if frame, _ := common.Frame(node.FrameID); frame != node.Frame {
+			t.Fatalf("heaptree test error: node %s does not point to the common table frame", node.FrameName())
+		}
+	})
+
+	if Merge(nil) != nil {
+		t.Fatal("heaptree test error: expected the merge of nil trees to be nil")
+	}
+}
+
+func TestFilter_FocusIgnore_OK(t *testing.T) {
+	tree := sampleTree()
+
+	// Focusing on worker() only keeps its path
+	filter, err := ParseFilter(`worker`, "", "", "")
+	if err != nil {
+		t.Fatalf("heaptree test error: %v", err)
+	}
+	focused := filter.Apply(tree)
+	if focused.Memory != 50 || len(focused.HeapAllocationLeafs) != 1 || focused.HeapAllocationLeafs[0].Memory != 50 {
+		t.Fatalf("heaptree test error: unexpected focused tree %+v", focused)
+	}
+
+	// Ignoring init() drops its 30 bytes and the 10 bytes below the threshold are kept by the root
+	filter, err = ParseFilter("", `^init`, "", "")
+	if err != nil {
+		t.Fatalf("heaptree test error: %v", err)
+	}
+	ignored := filter.Apply(tree)
+	if ignored.Memory != 80 || len(ignored.HeapAllocationLeafs) != 1 || ignored.HeapAllocationLeafs[0].Func != "alloc()" {
+		t.Fatalf("heaptree test error: unexpected tree after ignore %+v", ignored)
+	}
+
+	// The input tree is left untouched
+	if tree.Memory != 110 || len(tree.HeapAllocationLeafs) != 2 {
+		t.Fatal("heaptree test error: the input tree was modified")
+	}
+}
+
+func TestFilter_HidePrune_OK(t *testing.T) {
+
+	// Hiding alloc() and init() moves their callers under the root, main gathering the paths that only went through them
+	filter, err := ParseFilter("", "", `alloc|init`, "")
+	if err != nil {
+		t.Fatalf("heaptree test error: %v", err)
+	}
+	hidden := filter.Apply(sampleTree())
+	if hidden.Memory != 110 || len(hidden.HeapAllocationLeafs) != 2 {
+		t.Fatalf("heaptree test error: unexpected tree after hide %+v", hidden)
+	}
+	if main := hidden.HeapAllocationLeafs[1]; main.Func != "main" || main.Memory != 50 || main.ID != 0 {
+		t.Fatalf("heaptree test error: unexpected first leaf after hide %+v", main)
+	}
+
+	// Pruning below 40 bytes keeps the pruned bytes in the parents
+	filter, err = ParseFilter("", "", "", "40")
+	if err != nil {
+		t.Fatalf("heaptree test error: %v", err)
+	}
+	pruned := filter.Apply(sampleTree())
+	if pruned.Memory != 110 || pruned.ID != 1 || pruned.HeapAllocationLeafs[0].Memory != 70 || pruned.HeapAllocationLeafs[0].ID != 1 {
+		t.Fatalf("heaptree test error: unexpected pruned tree %+v", pruned)
+	}
+
+	// Percentages are relative to the filtered tree
+	filter, err = ParseFilter("", "", "", "50%")
+	if err != nil {
+		t.Fatalf("heaptree test error: %v", err)
+	}
+	if pruned := filter.Apply(sampleTree()); len(pruned.HeapAllocationLeafs) != 1 || len(pruned.HeapAllocationLeafs[0].HeapAllocationLeafs) != 0 {
+		t.Fatalf("heaptree test error: unexpected tree pruned by percentage %+v", pruned)
+	}
+}
+
+func TestParseFilter_KO(t *testing.T) {
+	for _, args := range [][4]string{
+		{"(", "", "", ""},
+		{"", "[", "", ""},
+		{"", "", "*", ""},
+		{"", "", "", "ten"},
+		{"", "", "", "-1%"},
+	} {
+		if _, err := ParseFilter(args[0], args[1], args[2], args[3]); err == nil {
+			t.Fatalf("heaptree test error: expected an error for %v", args)
+		}
+	}
+}
+
+func TestFrameTable_OK(t *testing.T) {
+	var ft FrameTable
+
+	line := "0x4006567: _dl_init (dl-init.c:117)"
+	id, frame := ft.Intern(Frame{Address: line[:9], Func: line[11:19]})
+	if id != 1 || frame.Address != "0x4006567" || frame.Func != "_dl_init" {
+		t.Fatalf("frame table test error: unexpected first frame %d, %+v", id, frame)
+	}
+
+	// Interning the same frame again returns the table frame
+	node := &HeapTree{Frame: &Frame{Address: "0x4006567", Func: "_dl_init"}}
+	ft.InternNode(node)
+	if node.FrameID != 1 || node.Frame != frame {
+		t.Fatal("frame table test error: the node does not share the table frame")
+	}
+
+	tree := sampleTree()
+	ft.InternTree(tree)
+	if ft.Len() != 6 || tree.HeapAllocationLeafs[0].HeapAllocationLeafs[1].FrameID != tree.HeapAllocationLeafs[1].HeapAllocationLeafs[0].FrameID {
+		t.Fatalf("frame table test error: expected 6 frames, main being shared, found %d", ft.Len())
+	}
+	if _, ok := ft.Frame(7); ok {
+		t.Fatal("frame table test error: frame 7 should not be found")
+	}
+
+	// JSON round trip
+	data, err := json.Marshal(ft)
+	if err != nil {
+		t.Fatalf("frame table test error: %v", err)
+	}
+	var decoded FrameTable
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("frame table test error: %v", err)
+	}
+	if id, _ := decoded.Intern(Frame{Address: "0x3", Func: "main"}); id != tree.HeapAllocationLeafs[1].HeapAllocationLeafs[0].FrameID || decoded.Len() != 6 {
+		t.Fatalf("frame table test error: unexpected decoded table %s", data)
+	}
+}
@@ -0,0 +1,38 @@
+package heaptree
+
+import "sort"
+
+// Inverts the heap tree into a callers tree: the root children are the outermost frames (e.g. main), and each path leads down to the direct caller of the allocation function.
+// Identical paths are merged, each node holding the bytes of the paths going through it. The root keeps the total of the input tree, bytes below massif's threshold included.
+// The input tree is left untouched
+func Invert(tree *HeapTree) *HeapTree {
+	if tree == nil {
+		return nil
+	}
+
+	inverted := &HeapTree{Memory: tree.Memory, FrameID: tree.FrameID, Frame: tree.Frame}
+	children := make(map[*HeapTree]map[frameKey]*HeapTree)
+
+	tree.paths(func(frames []*HeapTree, own int) {
+		// Follow the path from its outermost frame back down to the direct caller of the allocation function
+		current := inverted
+		for i := len(frames) - 1; i >= 0; i-- {
+			current = current.child(children, frames[i].FrameID, frames[i].Frame)
+			current.Memory += own
+		}
+	})
+
+	inverted.Walk(func(node *HeapTree, _ []*HeapTree) {
+		node.sortLeafs()
+	})
+
+	return inverted
+}
+
+// Sorts the node leafs by decreasing memory, as massif does, and sets the node ID to its number of leafs
+func (ht *HeapTree) sortLeafs() {
+	sort.SliceStable(ht.HeapAllocationLeafs, func(i, j int) bool {
+		return ht.HeapAllocationLeafs[i].Memory > ht.HeapAllocationLeafs[j].Memory
+	})
+	ht.ID = len(ht.HeapAllocationLeafs)
+}
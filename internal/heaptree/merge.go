@@ -0,0 +1,52 @@
+package heaptree
+
+// Merges heap trees of a single log by call path: nodes standing for the same frames from the allocation functions down are summed up.
+// Nil trees are skipped, and a nil tree is returned when there is nothing to merge. The input trees are left untouched.
+// Frames are matched by id, trees coming from different logs should be merged with FrameTable.Merge
+func Merge(trees ...*HeapTree) *HeapTree {
+	return merge(trees, func(node *HeapTree) (int, *Frame) {
+		return node.FrameID, node.Frame
+	})
+}
+
+// Merges heap trees like Merge, the trees possibly coming from different logs: their frames are interned in the table first,
+// and the merged nodes point to the table frames
+func (ft *FrameTable) Merge(trees ...*HeapTree) *HeapTree {
+	return merge(trees, func(node *HeapTree) (int, *Frame) {
+		return ft.Intern(*node.Frame)
+	})
+}
+
+// Merges the trees, frameOf returning the frame of the merged counterpart of a node
+func merge(trees []*HeapTree, frameOf func(node *HeapTree) (int, *Frame)) *HeapTree {
+	var merged *HeapTree
+	children := make(map[*HeapTree]map[frameKey]*HeapTree)
+
+	for _, tree := range trees {
+		if tree == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &HeapTree{}
+			merged.FrameID, merged.Frame = frameOf(tree)
+		}
+
+		// Map every node of the tree to its counterpart in the merged tree, parents being visited first
+		counterparts := make(map[*HeapTree]*HeapTree)
+		tree.Walk(func(node *HeapTree, ancestors []*HeapTree) {
+			counterpart := merged
+			if len(ancestors) > 0 {
+				id, frame := frameOf(node)
+				counterpart = counterparts[ancestors[len(ancestors)-1]].child(children, id, frame)
+			}
+			counterpart.Memory += node.Memory
+			counterparts[node] = counterpart
+		})
+	}
+
+	merged.Walk(func(node *HeapTree, _ []*HeapTree) {
+		node.sortLeafs()
+	})
+
+	return merged
+}
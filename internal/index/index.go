@@ -0,0 +1,313 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/MohamTahaB/massif-miner/internal/decompress"
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+	"github.com/MohamTahaB/massif-miner/internal/utils"
+)
+
+// Version of the sidecar index format, indexes of other versions are built again
+const Version = 1
+
+// Extension of the sidecar index, appended to the path of the massif file
+const SidecarExt = ".idx"
+
+const delimiter = "#-----------"
+
+// Define the position and the header of a snapshot in a massif file
+type Entry struct {
+	ID int `json:"id"`
+	// Byte offset of the delimiter starting the snapshot, and length of the snapshot up to the next one
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+
+	Time          int `json:"time"`
+	MemHeapB      int `json:"memHeapB"`
+	MemHeapExtraB int `json:"memHeapExtraB"`
+	MemStacksB    int `json:"memStacksB"`
+	Total         int `json:"total"`
+	// Kind of heap tree of the snapshot: "empty", "detailed" or "peak"
+	HeapTree string `json:"heapTree"`
+}
+
+// Define the index of a massif file: its metadata, and an entry per snapshot, in the file order
+type Index struct {
+	Version  int             `json:"version"`
+	Desc     string          `json:"desc"`
+	Cmd      string          `json:"cmd"`
+	TimeUnit outlog.TimeUnit `json:"timeUnit"`
+	// Whether massif profiled the stacks, from the desc or from the stack bytes of the snapshots
+	StacksProfiled bool `json:"stacksProfiled"`
+	// Size of the indexed file, to tell a stale sidecar apart
+	Size    int64   `json:"size"`
+	Entries []Entry `json:"entries"`
+}
+
+// Scans a massif file once, and indexes its snapshots. The heap trees are skipped, only the metadata and the snapshot headers are parsed.
+// Compressed files are not supported
+func Build(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	ix := &Index{Version: Version}
+
+	// Offsets in a compressed file cannot be seeked to
+	if header, _ := br.Peek(6); decompress.Sniff(header) != decompress.PLAIN {
+		return nil, fmt.Errorf("index error: %s files cannot be indexed, they should be decompressed first", decompress.Sniff(header))
+	}
+
+	var header []byte
+	var offset int64
+	var previous []byte
+	var current *Entry
+	// Whether the current entry is still in its header, i.e. before its heap_tree line
+	inHeader := false
+
+	for {
+		line, err := readLine(br)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("index error: %v", err)
+		}
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		trimmed := bytes.TrimSuffix(line, []byte("\n"))
+
+		if id, found := snapshotID(trimmed, previous); found {
+			start := offset - int64(len(previous)+1)
+
+			// The metadata runs up to the first snapshot
+			if len(ix.Entries) == 0 {
+				if err := ix.metaData(header[:len(header)-len(previous)-1]); err != nil {
+					return nil, err
+				}
+			} else {
+				current.Length = start - current.Offset
+			}
+
+			ix.Entries = append(ix.Entries, Entry{Offset: start})
+			current = &ix.Entries[len(ix.Entries)-1]
+			if current.ID, err = strconv.Atoi(string(id)); err != nil {
+				return nil, fmt.Errorf("index error: %v", err)
+			}
+			inHeader = true
+		} else if inHeader {
+			if err := current.header(trimmed); err != nil {
+				return nil, err
+			}
+			if current.HeapTree != "" {
+				inHeader = false
+				if current.MemStacksB > 0 {
+					ix.StacksProfiled = true
+				}
+			}
+		} else if len(ix.Entries) == 0 {
+			header = append(header, line...)
+		}
+
+		offset += int64(len(line))
+		previous = append(previous[:0], trimmed...)
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if len(ix.Entries) == 0 {
+		if err := ix.metaData(header); err != nil {
+			return nil, err
+		}
+	} else {
+		current.Length = offset - current.Offset
+		if current.HeapTree == "" {
+			return nil, fmt.Errorf("index error: snapshot %d has no heap_tree line", current.ID)
+		}
+	}
+	ix.Size = offset
+
+	return ix, nil
+}
+
+// Parses the metadata of the massif file, given the lines preceding its first snapshot
+func (ix *Index) metaData(header []byte) error {
+	dg := digger.InitDiggerSite(bytes.NewReader(header))
+	log := outlog.OutLog{}
+	if err := dg.MetaData(&log); err != nil {
+		return fmt.Errorf("index error: %v", err)
+	}
+
+	ix.Desc, ix.Cmd, ix.TimeUnit = log.Desc, log.Cmd, log.TimeUnit
+	ix.StacksProfiled = ix.StacksProfiled || log.StacksProfiled
+	return nil
+}
+
+// Sets the entry field the snapshot header line stands for
+func (e *Entry) header(line []byte) error {
+	if kind, found := utils.ExtractValue("heap_tree", line, false); found {
+		e.HeapTree = string(kind)
+		return nil
+	}
+
+	for _, field := range []struct {
+		label string
+		value *int
+	}{
+		{"time", &e.Time},
+		{"mem_heap_B", &e.MemHeapB},
+		{"mem_heap_extra_B", &e.MemHeapExtraB},
+		{"mem_stacks_B", &e.MemStacksB},
+	} {
+		if !bytes.HasPrefix(line, []byte(field.label+"=")) {
+			continue
+		}
+		value, found := utils.ExtractValue(field.label, line, true)
+		if !found {
+			return fmt.Errorf("index error: invalid header line %s in snapshot %d", line, e.ID)
+		}
+		var err error
+		if *field.value, err = strconv.Atoi(string(value)); err != nil {
+			return fmt.Errorf("index error: %v", err)
+		}
+		e.Total = e.MemHeapB + e.MemHeapExtraB + e.MemStacksB
+		return nil
+	}
+
+	if string(line) != delimiter {
+		return fmt.Errorf("index error: unexpected header line %s in snapshot %d", line, e.ID)
+	}
+	return nil
+}
+
+// Returns the entry of the snapshot with the given id, and whether it is found
+func (ix *Index) Entry(id int) (Entry, bool) {
+	// Massif numbers snapshots from 0, in order
+	if id >= 0 && id < len(ix.Entries) && ix.Entries[id].ID == id {
+		return ix.Entries[id], true
+	}
+	for _, entry := range ix.Entries {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Returns whether the snapshot of the entry has a heap tree, i.e. it is a detailed or a peak snapshot
+func (e Entry) Detailed() bool {
+	return e.HeapTree != "" && e.HeapTree != "empty"
+}
+
+// Returns the log of the indexed file with the snapshot headers only, and detects its peak.
+// Heap trees are left out, to be read on demand with a Reader, so that the log is cheap to hold in memory
+func (ix *Index) Log() outlog.OutLog {
+	log := outlog.OutLog{
+		Desc:           ix.Desc,
+		Cmd:            ix.Cmd,
+		TimeUnit:       ix.TimeUnit,
+		StacksProfiled: ix.StacksProfiled,
+		Snapshots:      make([]snapshot.Snapshot, 0, len(ix.Entries)),
+	}
+	for _, entry := range ix.Entries {
+		log.Snapshots = append(log.Snapshots, snapshot.Snapshot{
+			Id:             entry.ID,
+			Time:           entry.Time,
+			MemHeapB:       entry.MemHeapB,
+			MemHeapExtraB:  entry.MemHeapExtraB,
+			MemStacksB:     entry.MemStacksB,
+			StacksProfiled: ix.StacksProfiled,
+			IsPeak:         entry.HeapTree == "peak",
+		})
+	}
+	log.DetectPeak()
+	return log
+}
+
+// Returns the path of the sidecar index of a massif file
+func SidecarPath(path string) string {
+	return path + SidecarExt
+}
+
+// Writes the index as JSON
+func (ix *Index) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(ix)
+}
+
+// Reads an index written by Write
+func Read(r io.Reader) (*Index, error) {
+	ix := &Index{}
+	if err := json.NewDecoder(r).Decode(ix); err != nil {
+		return nil, fmt.Errorf("index error: %v", err)
+	}
+	if ix.Version != Version {
+		return nil, fmt.Errorf("index error: version %d not supported", ix.Version)
+	}
+	return ix, nil
+}
+
+// Returns the index of the massif file, from its sidecar when it is up to date, and builds it otherwise.
+// A built index is saved in the sidecar, failing to save it is not an error, e.g. in a read-only directory
+func Load(path string) (*Index, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("index error: %v", err)
+	}
+
+	if sidecar, err := os.Open(SidecarPath(path)); err == nil {
+		ix, err := Read(sidecar)
+		sidecar.Close()
+		if sidecarInfo, statErr := os.Stat(SidecarPath(path)); err == nil && statErr == nil && ix.Size == info.Size() && !sidecarInfo.ModTime().Before(info.ModTime()) {
+			return ix, nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("index error: %v", err)
+	}
+	defer file.Close()
+
+	ix, err := Build(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if sidecar, err := os.Create(SidecarPath(path)); err == nil {
+		err = errors.Join(ix.Write(sidecar), sidecar.Close())
+		if err != nil {
+			os.Remove(SidecarPath(path))
+		}
+	}
+
+	return ix, nil
+}
+
+// Returns the id of the snapshot the line starts, and whether it starts one, i.e. it is a "snapshot=id" line right after a delimiter
+func snapshotID(line, previous []byte) ([]byte, bool) {
+	if string(previous) != delimiter || !bytes.HasPrefix(line, []byte("snapshot=")) {
+		return nil, false
+	}
+	return utils.ExtractValue("snapshot", line, true)
+}
+
+// Reads a whole line, newline included, whatever its length
+func readLine(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+
+	long := append([]byte(nil), line...)
+	for err == bufio.ErrBufferFull {
+		line, err = br.ReadSlice('\n')
+		long = append(long, line...)
+	}
+	return long, err
+}
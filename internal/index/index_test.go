@@ -0,0 +1,207 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+)
+
+// Copies the massif.out log of the artifacts in a temporary directory, so that its sidecar does not land in the artifacts
+func artifactCopy(t *testing.T) string {
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "massif.out.log")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+	return path
+}
+
+func TestBuild_OK(t *testing.T) {
+
+	content, err := os.ReadFile("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	ix, err := Build(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if ix.Cmd != "./alloc_dealloc" || len(ix.Entries) != 60 || ix.Size != int64(len(content)) {
+		t.Fatalf("index test error: unexpected index %s, %d entries, size %d", ix.Cmd, len(ix.Entries), ix.Size)
+	}
+
+	peak, ok := ix.Entry(45)
+	if !ok || peak.HeapTree != "peak" || peak.MemHeapB != 165527 || peak.Total != 165527+3017 {
+		t.Fatalf("index test error: unexpected peak entry %+v", peak)
+	}
+
+	// Entries cover the whole file after the metadata, each starting at a snapshot boundary
+	for i, entry := range ix.Entries {
+		if !bytes.HasPrefix(content[entry.Offset:], []byte("#-----------\nsnapshot=")) {
+			t.Fatalf("index test error: entry %d does not start at a snapshot", entry.ID)
+		}
+		if i > 0 && ix.Entries[i-1].Offset+ix.Entries[i-1].Length != entry.Offset {
+			t.Fatalf("index test error: entry %d does not follow the previous one", entry.ID)
+		}
+	}
+	if last := ix.Entries[len(ix.Entries)-1]; last.Offset+last.Length != ix.Size {
+		t.Fatal("index test error: last entry does not run to the end of the file")
+	}
+}
+
+func TestBuild_KO(t *testing.T) {
+
+	for _, content := range []string{
+		"desc: --massif-out-file=massif.out\ntime_unit: i\n",
+		"desc: --massif-out-file=massif.out\ncmd: ./app\ntime_unit: i\n#-----------\nsnapshot=0\n#-----------\ntime=zero\n",
+		"desc: --massif-out-file=massif.out\ncmd: ./app\ntime_unit: i\n#-----------\nsnapshot=0\n#-----------\ntime=0\n",
+	} {
+		if _, err := Build(bytes.NewReader([]byte(content))); err == nil {
+			t.Fatalf("index test error: expected an error for %q", content)
+		}
+	}
+}
+
+func TestReaderSnapshot_OK(t *testing.T) {
+
+	path := artifactCopy(t)
+	rd, closer, err := Open(path)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+	defer closer.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+	defer file.Close()
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+
+	// Read the snapshots out of order, each one should match the fully parsed one
+	for _, id := range []int{45, 3, 58, 0, 45} {
+		ss, err := rd.Snapshot(id)
+		if err != nil {
+			t.Fatalf("index test error: %v", err)
+		}
+
+		expected := log.Snapshots[id]
+		if ss.Id != id || ss.Time != expected.Time || ss.MemHeapB != expected.MemHeapB || ss.IsPeak != expected.IsPeak {
+			t.Fatalf("index test error: unexpected snapshot %+v, expected %+v", ss, expected)
+		}
+		if frames(ss.HeapTree) != frames(expected.HeapTree) {
+			t.Fatalf("index test error: unexpected heap tree for snapshot %d", id)
+		}
+	}
+
+	if _, err := rd.Snapshot(60); err == nil {
+		t.Fatal("index test error: expected an error for a missing snapshot")
+	}
+}
+
+func TestLoad_Sidecar_OK(t *testing.T) {
+
+	path := artifactCopy(t)
+	built, err := Load(path)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+	if _, err := os.Stat(SidecarPath(path)); err != nil {
+		t.Fatalf("index test error: sidecar not saved: %v", err)
+	}
+
+	// The sidecar is used as long as it is up to date
+	loaded, err := Load(path)
+	if err != nil || len(loaded.Entries) != len(built.Entries) {
+		t.Fatalf("index test error: unexpected sidecar index, %v", err)
+	}
+
+	// Appending a snapshot makes it stale
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+	file.WriteString("#-----------\nsnapshot=60\n#-----------\ntime=1\nmem_heap_B=1\nmem_heap_extra_B=0\nmem_stacks_B=0\nheap_tree=empty\n")
+	file.Close()
+
+	rebuilt, err := Load(path)
+	if err != nil || len(rebuilt.Entries) != len(built.Entries)+1 {
+		t.Fatalf("index test error: stale sidecar not rebuilt, %v", err)
+	}
+}
+
+func TestReaderSnapshot_Stale_KO(t *testing.T) {
+
+	path := artifactCopy(t)
+	ix, err := Load(path)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+
+	// Shift the snapshots, the index no longer points at their beginning
+	content, _ := os.ReadFile(path)
+	shifted := append([]byte("desc: --x\n"), content[len("desc: "):]...)
+
+	if _, err := NewReader(bytes.NewReader(shifted), ix).Snapshot(45); err == nil {
+		t.Fatal("index test error: expected an error for a stale index")
+	}
+}
+
+// Returns a textual form of the frames and memory of the heap tree, frame ids aside
+func frames(tree *heaptree.HeapTree) string {
+	var b strings.Builder
+	tree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+		fmt.Fprintf(&b, "%d %d %s (%s)\n", len(ancestors), node.Memory, node.FrameName(), node.FuncFullDesc)
+	})
+	return b.String()
+}
+
+func TestLog_OK(t *testing.T) {
+	path := artifactCopy(t)
+	ix, err := Load(path)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+	defer file.Close()
+	parsed, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("index test error: %v", err)
+	}
+
+	// The log of the index matches the parsed one, heap trees aside
+	log := ix.Log()
+	if log.Cmd != parsed.Cmd || log.TimeUnit != parsed.TimeUnit || log.Peak != parsed.Peak || len(log.Snapshots) != len(parsed.Snapshots) {
+		t.Fatalf("index test error: unexpected log %s with %d snapshots and peak %+v", log.Cmd, len(log.Snapshots), log.Peak)
+	}
+	for i, ss := range log.Snapshots {
+		expected := parsed.Snapshots[i]
+		expected.HeapTree = nil
+		if ss != expected {
+			t.Fatalf("index test error: unexpected snapshot %+v, expected %+v", ss, expected)
+		}
+		if entry, _ := ix.Entry(ss.Id); entry.Detailed() != (parsed.Snapshots[i].HeapTree != nil) {
+			t.Fatalf("index test error: unexpected detailed flag for snapshot %d", ss.Id)
+		}
+	}
+}
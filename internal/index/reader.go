@@ -0,0 +1,85 @@
+package index
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Define a reader of the snapshots of a massif file, seeking to them through the file index instead of holding the file in memory.
+// It is safe for concurrent use
+type Reader struct {
+	r     io.ReaderAt
+	index *Index
+
+	// Holds the metadata and the frames of the snapshots read so far, the snapshots themselves are not kept
+	mu  sync.Mutex
+	log outlog.OutLog
+}
+
+// Returns a reader of the massif file, through the given index
+func NewReader(r io.ReaderAt, ix *Index) *Reader {
+	return &Reader{
+		r:     r,
+		index: ix,
+		log:   outlog.OutLog{Desc: ix.Desc, Cmd: ix.Cmd, TimeUnit: ix.TimeUnit, StacksProfiled: ix.StacksProfiled},
+	}
+}
+
+// Opens the massif file at the given path along with its index, loaded from its sidecar or built if needed.
+// The file should be closed with the returned closer once the reader is not used anymore
+func Open(path string) (*Reader, io.Closer, error) {
+	ix, err := Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("index error: %v", err)
+	}
+
+	return NewReader(file, ix), file, nil
+}
+
+// Returns the index of the reader
+func (rd *Reader) Index() *Index {
+	return rd.index
+}
+
+// Seeks to the snapshot with the given id, and parses it, heap tree included.
+// Its frames are interned in a table shared by the snapshots of the reader, see Frames
+func (rd *Reader) Snapshot(id int) (snapshot.Snapshot, error) {
+	entry, ok := rd.index.Entry(id)
+	if !ok {
+		return snapshot.Snapshot{}, fmt.Errorf("index error: snapshot %d not found", id)
+	}
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	rd.log.Snapshots = rd.log.Snapshots[:0]
+	if err := digger.FetchSnapshots(io.NewSectionReader(rd.r, entry.Offset, entry.Length), &rd.log); err != nil {
+		return snapshot.Snapshot{}, err
+	}
+	if len(rd.log.Snapshots) != 1 || rd.log.Snapshots[0].Id != id {
+		return snapshot.Snapshot{}, fmt.Errorf("index error: snapshot %d not found at offset %d, the index may be stale", id, entry.Offset)
+	}
+
+	ss := rd.log.Snapshots[0]
+	ss.StacksProfiled = rd.index.StacksProfiled
+	rd.log.Snapshots[0] = snapshot.Snapshot{}
+	return ss, nil
+}
+
+// Returns the number of frames interned by the snapshots read so far
+func (rd *Reader) Frames() int {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	return rd.log.Frames.Len()
+}
@@ -0,0 +1,209 @@
+package leak
+
+import (
+	"sort"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/utils"
+)
+
+// Define the bytes held by a call path in a given detailed snapshot
+type Sample struct {
+	SnapshotID int `json:"snapshotId"`
+	Time       int `json:"time"`
+	Bytes      int `json:"bytes"`
+}
+
+// Define a call path tracked across the detailed snapshots of a log, along with its growth metrics
+type Site struct {
+	// Frames from the direct caller of the allocation function outwards
+	Path    []string `json:"path"`
+	Samples []Sample `json:"samples"`
+
+	// Share of the consecutive samples that do not drop, in [0, 1]
+	Monotonicity float64 `json:"monotonicity"`
+	// Whether the bytes never drop across the samples
+	Monotonic bool `json:"monotonic"`
+	// Least squares growth rate, in bytes per time unit of the log
+	Slope float64 `json:"slope"`
+	// Bytes still live in the final detailed snapshot
+	FinalBytes int `json:"finalBytes"`
+}
+
+// Identifies a call path by the site of its parent path, nil for the direct callers of the allocation function, and its last frame
+type pathKey struct {
+	parent  *Site
+	frameID int
+	// Only set for frames that are not interned
+	frame string
+}
+
+// Define the thresholds used to tell suspect sites apart from the rest
+type Options struct {
+	// Minimum number of detailed snapshots the path should hold bytes in
+	MinSamples int
+	// Minimum monotonicity for a site to be suspect
+	MinMonotonicity float64
+}
+
+// Returns the options used when none are specified
+func DefaultOptions() Options {
+	return Options{
+		MinSamples:      3,
+		MinMonotonicity: 0.9,
+	}
+}
+
+// Tracks every call path across the detailed snapshots of the log, and computes their growth metrics.
+// A path missing from a detailed snapshot is considered as holding no bytes at that time
+func Analyze(log *outlog.OutLog) []Site {
+	sites := make(map[pathKey]*Site)
+	var keys []pathKey
+	detailed := 0
+
+	for _, ss := range log.Snapshots {
+		if ss.HeapTree == nil {
+			continue
+		}
+
+		// Paths are identified by the site of their parent path and their last frame, interned frames being compared by id
+		nodeSites := make(map[*heaptree.HeapTree]*Site)
+		ss.HeapTree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+			// The root stands for the allocation functions, not a call path
+			if len(ancestors) == 0 {
+				return
+			}
+
+			key := pathKey{parent: nodeSites[ancestors[len(ancestors)-1]], frameID: node.FrameID}
+			if node.FrameID == 0 {
+				key.frame = node.FrameName()
+			}
+
+			site, ok := sites[key]
+			if !ok {
+				var path []string
+				if key.parent != nil {
+					path = append(path, key.parent.Path...)
+				}
+				path = append(path, node.FrameName())
+
+				// Backfill the detailed snapshots preceding the first appearance of the path
+				site = &Site{Path: path, Samples: make([]Sample, 0, detailed+1)}
+				for _, previous := range log.Snapshots {
+					if len(site.Samples) == detailed {
+						break
+					}
+					if previous.HeapTree != nil {
+						site.Samples = append(site.Samples, Sample{SnapshotID: previous.Id, Time: previous.Time})
+					}
+				}
+				sites[key] = site
+				keys = append(keys, key)
+			}
+			nodeSites[node] = site
+
+			// The same path might show up twice in a snapshot, e.g. with recursion below the threshold
+			if len(site.Samples) == detailed+1 {
+				site.Samples[detailed].Bytes += node.Memory
+				return
+			}
+			site.Samples = append(site.Samples, Sample{SnapshotID: ss.Id, Time: ss.Time, Bytes: node.Memory})
+		})
+
+		// Pad the paths absent from this snapshot
+		for _, key := range keys {
+			if site := sites[key]; len(site.Samples) == detailed {
+				site.Samples = append(site.Samples, Sample{SnapshotID: ss.Id, Time: ss.Time})
+			}
+		}
+		detailed++
+	}
+
+	result := make([]Site, 0, len(keys))
+	for _, key := range keys {
+		site := sites[key]
+		site.computeMetrics()
+		result = append(result, *site)
+	}
+
+	return result
+}
+
+// Returns the call paths that grow monotonically enough to be suspected of leaking, most suspect first.
+// Sites are ranked by monotonicity, then growth rate, then bytes live in the final detailed snapshot
+func Suspects(log *outlog.OutLog, opts Options) []Site {
+	var suspects []Site
+	for _, site := range Analyze(log) {
+		if site.Slope <= 0 || site.Monotonicity < opts.MinMonotonicity || site.nonZeroSamples() < opts.MinSamples {
+			continue
+		}
+		suspects = append(suspects, site)
+	}
+
+	sort.SliceStable(suspects, func(i, j int) bool {
+		if suspects[i].Monotonicity != suspects[j].Monotonicity {
+			return suspects[i].Monotonicity > suspects[j].Monotonicity
+		}
+		if suspects[i].Slope != suspects[j].Slope {
+			return suspects[i].Slope > suspects[j].Slope
+		}
+		return suspects[i].FinalBytes > suspects[j].FinalBytes
+	})
+
+	return suspects
+}
+
+// Computes the monotonicity, slope and final bytes of the site from its samples
+func (site *Site) computeMetrics() {
+	if len(site.Samples) == 0 {
+		return
+	}
+
+	site.FinalBytes = site.Samples[len(site.Samples)-1].Bytes
+
+	// Only consider the samples from the first appearance of the path onwards
+	first := 0
+	for first < len(site.Samples)-1 && site.Samples[first].Bytes == 0 {
+		first++
+	}
+	samples := site.Samples[first:]
+
+	site.Monotonic = true
+	site.Monotonicity = 1
+	if len(samples) > 1 {
+		steady := 0
+		for i := 1; i < len(samples); i++ {
+			if samples[i].Bytes >= samples[i-1].Bytes {
+				steady++
+			} else {
+				site.Monotonic = false
+			}
+		}
+		site.Monotonicity = float64(steady) / float64(len(samples)-1)
+	}
+
+	site.Slope = slope(samples)
+}
+
+// Returns the number of samples where the path holds bytes
+func (site *Site) nonZeroSamples() int {
+	count := 0
+	for _, sample := range site.Samples {
+		if sample.Bytes > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Fits the bytes of the samples against their time with least squares, and returns the slope
+func slope(samples []Sample) float64 {
+	times := make([]float64, len(samples))
+	bytes := make([]float64, len(samples))
+	for i, sample := range samples {
+		times[i] = float64(sample.Time)
+		bytes[i] = float64(sample.Bytes)
+	}
+	return utils.LeastSquaresSlope(times, bytes)
+}
@@ -0,0 +1,124 @@
+package leak
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("leak test error: error reading from the massif.out: %v", err)
+	}
+	return log
+}
+
+// Builds a detailed snapshot with a leaking and a steady call path under the root
+func detailedSnapshot(id, time, leaking, steady int) snapshot.Snapshot {
+	return snapshot.Snapshot{
+		Id:       id,
+		Time:     time,
+		MemHeapB: leaking + steady,
+		HeapTree: &heaptree.HeapTree{
+			Frame:  &heaptree.Frame{Address: "root", Func: "heap allocation functions"},
+			Memory: leaking + steady,
+			HeapAllocationLeafs: []*heaptree.HeapTree{
+				{Frame: &heaptree.Frame{Address: "0x1", Func: "leaky()"}, Memory: leaking},
+				{Frame: &heaptree.Frame{Address: "0x2", Func: "steady()"}, Memory: steady},
+			},
+		},
+	}
+}
+
+func TestSuspects_OK(t *testing.T) {
+	ol := outlog.OutLog{
+		Snapshots: []snapshot.Snapshot{
+			detailedSnapshot(0, 0, 100, 500),
+			{Id: 1, Time: 50, MemHeapB: 650},
+			detailedSnapshot(2, 100, 200, 400),
+			detailedSnapshot(3, 200, 300, 500),
+			detailedSnapshot(4, 300, 400, 300),
+		},
+	}
+
+	suspects := Suspects(&ol, DefaultOptions())
+	if len(suspects) != 1 {
+		t.Fatalf("leak test error: expected 1 suspect, found %d", len(suspects))
+	}
+
+	site := suspects[0]
+	if len(site.Path) != 1 || site.Path[0] != "0x1: leaky()" {
+		t.Fatalf("leak test error: unexpected suspect path %v", site.Path)
+	}
+	if !site.Monotonic || site.Monotonicity != 1 {
+		t.Fatalf("leak test error: expected the suspect to be monotonic, found %f", site.Monotonicity)
+	}
+	if site.Slope != 1 {
+		t.Fatalf("leak test error: expected a slope of 1 byte per time unit, found %f", site.Slope)
+	}
+	if site.FinalBytes != 400 {
+		t.Fatalf("leak test error: expected 400 final bytes, found %d", site.FinalBytes)
+	}
+	if len(site.Samples) != 4 {
+		t.Fatalf("leak test error: expected 4 samples, one per detailed snapshot, found %d", len(site.Samples))
+	}
+}
+
+func TestAnalyze_LatePath_OK(t *testing.T) {
+	late := detailedSnapshot(1, 100, 10, 20)
+	late.HeapTree.HeapAllocationLeafs = append(late.HeapTree.HeapAllocationLeafs, &heaptree.HeapTree{Frame: &heaptree.Frame{Address: "0x3", Func: "late()"}, Memory: 30})
+
+	ol := outlog.OutLog{
+		Snapshots: []snapshot.Snapshot{
+			detailedSnapshot(0, 0, 10, 20),
+			late,
+			detailedSnapshot(2, 200, 10, 20),
+		},
+	}
+
+	for _, site := range Analyze(&ol) {
+		if site.Path[0] != "0x3: late()" {
+			continue
+		}
+
+		// The path is padded with empty samples before and after its appearance
+		if len(site.Samples) != 3 || site.Samples[0].Bytes != 0 || site.Samples[1].Bytes != 30 || site.Samples[2].Bytes != 0 {
+			t.Fatalf("leak test error: unexpected samples for the late path: %v", site.Samples)
+		}
+		if site.Monotonic || site.FinalBytes != 0 {
+			t.Fatal("leak test error: the late path should not be monotonic nor live at the end")
+		}
+		return
+	}
+
+	t.Fatal("leak test error: the late path is not tracked")
+}
+
+func TestSuspectsOnMassifLog_OK(t *testing.T) {
+
+	ol := parseArtifact(t)
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	sites := Analyze(&ol)
+	if len(sites) != 14 {
+		t.Fatalf("leak test error: expected 14 call paths, found %d", len(sites))
+	}
+
+	// The allocations of the fixture go up and down, nothing should be suspect
+	if suspects := Suspects(&ol, DefaultOptions()); len(suspects) != 0 {
+		t.Fatalf("leak test error: expected no suspects, found %d", len(suspects))
+	}
+}
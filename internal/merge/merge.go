@@ -0,0 +1,133 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Define a profile of one of the processes to merge, e.g. parsed from a massif.out.<pid> file
+type Process struct {
+	Name string
+	Log  *outlog.OutLog
+	// Shifts the process snapshot times onto the common time axis, e.g. for a worker forked late
+	Offset int
+}
+
+// Define the memory of a process at a point of the merged timeline
+type Contribution struct {
+	// Index of the process in the merged processes
+	Process int `json:"process"`
+	// Snapshot of the process the memory comes from
+	SnapshotID    int `json:"snapshotId"`
+	MemHeapB      int `json:"memHeapB"`
+	MemHeapExtraB int `json:"memHeapExtraB"`
+	MemStacksB    int `json:"memStacksB"`
+}
+
+// Define the result of a merge: a combined log, along with the per process breakdown of every one of its snapshots
+type Merged struct {
+	Log       outlog.OutLog `json:"log"`
+	Processes []string      `json:"processes"`
+	// Contributions of the live processes, indexed as the combined log snapshots
+	Breakdowns [][]Contribution `json:"breakdowns"`
+}
+
+// Merges the profiles of several processes sharing the same time unit into a combined log.
+// The combined log has a snapshot at every time one of the processes has a snapshot at. A process contributes the memory of its latest snapshot at or before that time, and nothing past its last snapshot, as it has exited.
+// Heap trees of the detailed snapshots that coincide in time are merged by call path, the bytes of the processes without a heap tree at that time being left to the root
+func Merge(processes []Process) (Merged, error) {
+	if len(processes) == 0 {
+		return Merged{}, fmt.Errorf("merge error: no processes to merge")
+	}
+	for _, process := range processes {
+		if process.Log == nil {
+			return Merged{}, fmt.Errorf("merge error: process %s has no log", process.Name)
+		}
+	}
+
+	merged := Merged{
+		Log: outlog.OutLog{
+			TimeUnit: processes[0].Log.TimeUnit,
+		},
+	}
+
+	var descs, cmds []string
+	var times []int
+	seen := make(map[int]bool)
+
+	for _, process := range processes {
+		if process.Log.TimeUnit != merged.Log.TimeUnit {
+			return Merged{}, fmt.Errorf("merge error: process %s time unit %s differs from %s", process.Name, process.Log.TimeUnit, merged.Log.TimeUnit)
+		}
+
+		merged.Processes = append(merged.Processes, process.Name)
+		descs = append(descs, process.Log.Desc)
+		cmds = append(cmds, process.Log.Cmd)
+		merged.Log.StacksProfiled = merged.Log.StacksProfiled || process.Log.StacksProfiled
+
+		for _, ss := range process.Log.Snapshots {
+			if t := ss.Time + process.Offset; !seen[t] {
+				seen[t] = true
+				times = append(times, t)
+			}
+		}
+	}
+	sort.Ints(times)
+
+	merged.Log.Desc = strings.Join(descs, "; ")
+	merged.Log.Cmd = strings.Join(cmds, "; ")
+
+	// Walk the snapshots of every process along the common time axis
+	cursors := make([]int, len(processes))
+	for id, t := range times {
+		ss := snapshot.Snapshot{Id: id, Time: t, StacksProfiled: merged.Log.StacksProfiled}
+		var contributions []Contribution
+		var trees []*heaptree.HeapTree
+
+		for p, process := range processes {
+			snapshots := process.Log.Snapshots
+			for cursors[p] < len(snapshots)-1 && snapshots[cursors[p]+1].Time+process.Offset <= t {
+				cursors[p]++
+			}
+
+			// Skip the processes that are not started yet or already exited
+			if len(snapshots) == 0 || snapshots[0].Time+process.Offset > t || snapshots[len(snapshots)-1].Time+process.Offset < t {
+				continue
+			}
+
+			current := snapshots[cursors[p]]
+			contributions = append(contributions, Contribution{
+				Process:       p,
+				SnapshotID:    current.Id,
+				MemHeapB:      current.MemHeapB,
+				MemHeapExtraB: current.MemHeapExtraB,
+				MemStacksB:    current.MemStacksB,
+			})
+			ss.MemHeapB += current.MemHeapB
+			ss.MemHeapExtraB += current.MemHeapExtraB
+			ss.MemStacksB += current.MemStacksB
+
+			if current.HeapTree != nil && current.Time+process.Offset == t {
+				trees = append(trees, current.HeapTree)
+			}
+		}
+
+		// Frame ids of the processes are of their own tables, frames are matched in the table of the merged log
+		if ss.HeapTree = merged.Log.Frames.Merge(trees...); ss.HeapTree != nil {
+			ss.HeapTree.Memory = ss.MemHeapB
+		}
+
+		merged.Log.Snapshots = append(merged.Log.Snapshots, ss)
+		merged.Breakdowns = append(merged.Breakdowns, contributions)
+	}
+
+	// Massif flagged the peaks of the processes, not the one of the combined log, which is only computed
+	merged.Log.DetectPeak()
+
+	return merged, nil
+}
@@ -0,0 +1,124 @@
+package merge
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("merge test error: error reading from the massif.out: %v", err)
+	}
+	return log
+}
+
+// Builds a detailed heap tree where main allocates through the given function
+func treeOf(fn string, memory int) *heaptree.HeapTree {
+	return &heaptree.HeapTree{
+		Frame: &heaptree.Frame{Address: "root", Func: "heap allocation functions"}, Memory: memory,
+		HeapAllocationLeafs: []*heaptree.HeapTree{
+			{Frame: &heaptree.Frame{Address: "0x1", Func: fn}, Memory: memory, HeapAllocationLeafs: []*heaptree.HeapTree{
+				{Frame: &heaptree.Frame{Address: "0x2", Func: "main"}, Memory: memory},
+			}},
+		},
+	}
+}
+
+func TestMerge_OK(t *testing.T) {
+	parent := outlog.OutLog{Cmd: "./server", Snapshots: []snapshot.Snapshot{
+		{Id: 0, Time: 0, MemHeapB: 100},
+		{Id: 1, Time: 100, MemHeapB: 300, HeapTree: treeOf("serve()", 300)},
+		{Id: 2, Time: 300, MemHeapB: 200},
+	}}
+
+	// The worker is forked at time 50 of the parent
+	worker := outlog.OutLog{Cmd: "./server", Snapshots: []snapshot.Snapshot{
+		{Id: 0, Time: 0, MemHeapB: 10},
+		{Id: 1, Time: 50, MemHeapB: 40, HeapTree: treeOf("work()", 40)},
+		{Id: 2, Time: 150, MemHeapB: 20},
+	}}
+
+	merged, err := Merge([]Process{{Name: "1000", Log: &parent}, {Name: "1001", Log: &worker, Offset: 50}})
+	if err != nil {
+		t.Fatalf("merge test error: %v", err)
+	}
+
+	// Times 0, 50, 100, 200 and 300
+	expected := []struct {
+		time, heap, contributions int
+	}{{0, 100, 1}, {50, 110, 2}, {100, 340, 2}, {200, 320, 2}, {300, 200, 1}}
+
+	if len(merged.Log.Snapshots) != len(expected) {
+		t.Fatalf("merge test error: expected %d snapshots, found %d", len(expected), len(merged.Log.Snapshots))
+	}
+	for i, e := range expected {
+		ss := merged.Log.Snapshots[i]
+		if ss.Time != e.time || ss.MemHeapB != e.heap || len(merged.Breakdowns[i]) != e.contributions {
+			t.Fatalf("merge test error: expected %v at %d, found time %d, heap %d and %d contributions", e, i, ss.Time, ss.MemHeapB, len(merged.Breakdowns[i]))
+		}
+	}
+
+	// Both detailed snapshots coincide at time 100
+	tree := merged.Log.Snapshots[2].HeapTree
+	if tree == nil || tree.Memory != 340 || len(tree.HeapAllocationLeafs) != 2 || tree.HeapAllocationLeafs[0].Func != "serve()" {
+		t.Fatalf("merge test error: unexpected merged tree %+v", tree)
+	}
+	if breakdown := merged.Breakdowns[2][1]; breakdown.Process != 1 || breakdown.SnapshotID != 1 || breakdown.MemHeapB != 40 {
+		t.Fatalf("merge test error: unexpected worker contribution %+v", breakdown)
+	}
+
+	if merged.Log.Peak.MaxID != 2 || merged.Log.Peak.FlaggedID != -1 {
+		t.Fatalf("merge test error: unexpected peak %+v", merged.Log.Peak)
+	}
+}
+
+func TestMerge_KO(t *testing.T) {
+	if _, err := Merge(nil); err == nil {
+		t.Fatal("merge test error: expected an error when merging nothing")
+	}
+
+	inInstructions, inMS := outlog.OutLog{TimeUnit: outlog.I}, outlog.OutLog{TimeUnit: outlog.MS}
+	if _, err := Merge([]Process{{Name: "a", Log: &inInstructions}, {Name: "b", Log: &inMS}}); err == nil {
+		t.Fatal("merge test error: expected an error when merging different time units")
+	}
+
+	for _, processes := range [][]Process{{{Name: "a"}}, {{Name: "a", Log: &inInstructions}, {Name: "b"}}} {
+		if _, err := Merge(processes); err == nil || err.Error() != fmt.Sprintf("merge error: process %s has no log", processes[len(processes)-1].Name) {
+			t.Fatalf("merge test error: expected a missing log error, found %v", err)
+		}
+	}
+}
+
+func TestMergeOnMassifLog_OK(t *testing.T) {
+
+	ol := parseArtifact(t)
+
+	// Merging a process with itself doubles everything
+	merged, err := Merge([]Process{{Name: "a", Log: &ol}, {Name: "b", Log: &ol}})
+	if err != nil {
+		t.Fatalf("merge test error: %v", err)
+	}
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if len(merged.Log.Snapshots) != 60 || merged.Log.Peak.MaxID != 45 || merged.Log.Peak.MaxTotal != 2*(165527+3017) {
+		t.Fatalf("merge test error: unexpected merged log with %d snapshots and peak %+v", len(merged.Log.Snapshots), merged.Log.Peak)
+	}
+	if tree := merged.Log.Snapshots[45].HeapTree; tree.Memory != 2*165527 || tree.HeapAllocationLeafs[0].Memory != 2*90775 {
+		t.Fatalf("merge test error: unexpected merged peak tree %+v", tree)
+	}
+}
@@ -0,0 +1,165 @@
+package objects
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+)
+
+// Object bytes could not be attributed to, e.g. bytes below massif's threshold or paths without any object info
+const Unknown = "(unknown)"
+
+// Picks the object file a call path is attributed to.
+// The objects are given from the allocation function outwards, with an empty string for frames without object info (e.g. "(dl-init.c:70)")
+type Policy func(objects []string) (string, bool)
+
+// Define the heap bytes held by an object file
+type Usage struct {
+	Object  string  `json:"object"`
+	Bytes   int     `json:"bytes"`
+	Percent float64 `json:"percent"`
+}
+
+// Define the heap usage per object file of a detailed snapshot
+type SnapshotUsage struct {
+	SnapshotID int     `json:"snapshotId"`
+	Time       int     `json:"time"`
+	Objects    []Usage `json:"objects"`
+}
+
+// Prefixes of the object files considered as part of the system
+var SystemPrefixes = []string{"/lib/", "/lib32/", "/lib64/", "/usr/lib/", "/usr/lib32/", "/usr/lib64/", "/usr/libexec/"}
+
+// Returns a policy attributing a call path to the object closest to the allocation function
+func FirstObject() Policy {
+	return func(objects []string) (string, bool) {
+		for _, object := range objects {
+			if object != "" {
+				return object, true
+			}
+		}
+		return "", false
+	}
+}
+
+// Returns a policy attributing a call path to the first object that does not belong to the system, given the system prefixes.
+// Falls back to SystemPrefixes when no prefix is given
+func FirstNonSystemObject(systemPrefixes ...string) Policy {
+	if len(systemPrefixes) == 0 {
+		systemPrefixes = SystemPrefixes
+	}
+
+	return func(objects []string) (string, bool) {
+		for _, object := range objects {
+			if object == "" || hasAnyPrefix(object, systemPrefixes) {
+				continue
+			}
+			return object, true
+		}
+		return "", false
+	}
+}
+
+// Returns a policy attributing a call path to the first frame in the given binary, e.g. the cmd of the log.
+// Binaries are compared on their base name, as the cmd is usually a relative path
+func FirstInBinary(binary string) Policy {
+	if fields := strings.Fields(binary); len(fields) > 0 {
+		binary = fields[0]
+	}
+	base := filepath.Base(binary)
+
+	return func(objects []string) (string, bool) {
+		for _, object := range objects {
+			if object != "" && filepath.Base(object) == base {
+				return object, true
+			}
+		}
+		return "", false
+	}
+}
+
+// Attributes the bytes of every leaf-to-root path of the heap tree to an object file, using the policy.
+// Paths the policy does not match are attributed to their first object, or Unknown when there is none.
+// Returns the usages sorted by decreasing bytes
+func Attribute(tree *heaptree.HeapTree, policy Policy) []Usage {
+	if tree == nil {
+		return nil
+	}
+
+	fallback := FirstObject()
+	bytes := make(map[string]int)
+
+	tree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+		// Bytes of the node that are not accounted for by its children end their path at the node
+		own := node.Memory
+		for _, leaf := range node.HeapAllocationLeafs {
+			own -= leaf.Memory
+		}
+		if own <= 0 {
+			return
+		}
+
+		// Skip the root, it stands for the allocation functions
+		objects := make([]string, 0, len(ancestors))
+		if len(ancestors) > 0 {
+			for _, ancestor := range ancestors[1:] {
+				objects = append(objects, ancestor.FuncFullDesc)
+			}
+			objects = append(objects, node.FuncFullDesc)
+		}
+
+		object, ok := policy(objects)
+		if !ok {
+			if object, ok = fallback(objects); !ok {
+				object = Unknown
+			}
+		}
+		bytes[object] += own
+	})
+
+	usages := make([]Usage, 0, len(bytes))
+	for object, b := range bytes {
+		usage := Usage{Object: object, Bytes: b}
+		if tree.Memory > 0 {
+			usage.Percent = 100 * float64(b) / float64(tree.Memory)
+		}
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Bytes != usages[j].Bytes {
+			return usages[i].Bytes > usages[j].Bytes
+		}
+		return usages[i].Object < usages[j].Object
+	})
+
+	return usages
+}
+
+// Attributes the heap of every detailed snapshot of the log to object files, using the policy
+func BySnapshot(log *outlog.OutLog, policy Policy) []SnapshotUsage {
+	var result []SnapshotUsage
+	for _, ss := range log.Snapshots {
+		if ss.HeapTree == nil {
+			continue
+		}
+		result = append(result, SnapshotUsage{
+			SnapshotID: ss.Id,
+			Time:       ss.Time,
+			Objects:    Attribute(ss.HeapTree, policy),
+		})
+	}
+	return result
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package objects
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	ol, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("objects test error: error reading from the massif.out: %v", err)
+	}
+	return ol
+}
+
+func TestAttribute_FirstObject_OK(t *testing.T) {
+	ol := parseArtifact(t)
+
+	// CAUTION: change in the artifacts should be taken into account here as well, snapshot 4 is the first detailed one
+	usages := Attribute(ol.Snapshots[4].HeapTree, FirstObject())
+
+	expected := []Usage{
+		{Object: "/usr/lib/x86_64-linux-gnu/libstdc++.so.6.0.30", Bytes: 72704},
+		{Object: "/home/taha/internship/testdir/alloc_dealloc", Bytes: 21776},
+		{Object: Unknown, Bytes: 512},
+	}
+	if len(usages) != len(expected) {
+		t.Fatalf("objects test error: expected %d objects, found %v", len(expected), usages)
+	}
+	for i := range expected {
+		if usages[i].Object != expected[i].Object || usages[i].Bytes != expected[i].Bytes {
+			t.Fatalf("objects test error: expected %v at %d, found %v", expected[i], i, usages[i])
+		}
+	}
+}
+
+func TestAttribute_FirstNonSystemObject_OK(t *testing.T) {
+	ol := parseArtifact(t)
+
+	// libstdc++ and ld-linux both are system objects, the path falls back to the first object
+	usages := Attribute(ol.Snapshots[4].HeapTree, FirstNonSystemObject())
+	if usages[0].Object != "/usr/lib/x86_64-linux-gnu/libstdc++.so.6.0.30" || usages[0].Bytes != 72704 {
+		t.Fatalf("objects test error: unexpected first object %v", usages[0])
+	}
+
+	// With only libstdc++ considered part of the system, the path is attributed to the loader
+	usages = Attribute(ol.Snapshots[4].HeapTree, FirstNonSystemObject("/usr/lib/x86_64-linux-gnu/libstdc"))
+	if usages[0].Object != "/usr/lib/x86_64-linux-gnu/ld-linux-x86-64.so.2" || usages[0].Bytes != 72704 {
+		t.Fatalf("objects test error: unexpected first object %v", usages[0])
+	}
+}
+
+func TestBySnapshot_FirstInBinary_OK(t *testing.T) {
+	ol := parseArtifact(t)
+
+	usages := BySnapshot(&ol, FirstInBinary(ol.Cmd))
+	if len(usages) != 8 {
+		t.Fatalf("objects test error: expected 8 detailed snapshots, found %d", len(usages))
+	}
+
+	// Snapshot 45 is the peak, the vector allocations through libstdc++ templates belong to the binary
+	var peak SnapshotUsage
+	for _, usage := range usages {
+		if usage.SnapshotID == 45 {
+			peak = usage
+		}
+	}
+
+	total := 0
+	for _, usage := range peak.Objects {
+		total += usage.Bytes
+		if usage.Object == "/home/taha/internship/testdir/alloc_dealloc" && usage.Bytes != 92823 {
+			t.Fatalf("objects test error: expected the binary to hold 92823 bytes, found %d", usage.Bytes)
+		}
+	}
+	if total != ol.Snapshots[45].MemHeapB {
+		t.Fatalf("objects test error: expected the objects to hold %d bytes, found %d", ol.Snapshots[45].MemHeapB, total)
+	}
+}
@@ -0,0 +1,107 @@
+package outlog
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Define the version 0 encoding of a log, written before the schema was versioned
+type v0OutLog struct {
+	Desc      string
+	Cmd       string
+	TimeUnit  int
+	Snapshots []v0Snapshot
+	// Missing from the logs written before the peak was detected
+	Peak           *Peak
+	StacksProfiled bool
+	// Missing from the logs written before the frames were interned
+	Frames heaptree.FrameTable
+}
+
+type v0Snapshot struct {
+	Id             int
+	Time           int
+	MemHeapB       int
+	MemHeapExtraB  int
+	MemStacksB     int `json:"memStacks"`
+	StacksProfiled bool
+	HeapTree       *v0HeapTree
+	IsPeak         bool
+}
+
+type v0HeapTree struct {
+	ID                  int
+	Memory              int
+	FrameID             int
+	Address             string
+	Func                string
+	FuncFullDesc        string
+	HeapAllocationLeafs []*v0HeapTree
+}
+
+// Decodes a version 0 log, filling in what it misses the way the parser does
+func (log *OutLog) unmarshalV0(data []byte) error {
+	var v0 v0OutLog
+	if err := json.Unmarshal(data, &v0); err != nil {
+		return err
+	}
+
+	// Version 0 encoded the time unit as its number, AUTO included
+	if unit := TimeUnit(v0.TimeUnit); !unit.IsResolved() {
+		return fmt.Errorf("time unit %s not supported", unit)
+	}
+
+	*log = OutLog{
+		Desc:           v0.Desc,
+		Cmd:            v0.Cmd,
+		TimeUnit:       TimeUnit(v0.TimeUnit),
+		Snapshots:      make([]snapshot.Snapshot, len(v0.Snapshots)),
+		StacksProfiled: v0.StacksProfiled,
+		Frames:         v0.Frames,
+	}
+	intern := v0.Frames.Len() == 0
+	for i, ss := range v0.Snapshots {
+		log.Snapshots[i] = snapshot.Snapshot{
+			Id:             ss.Id,
+			Time:           ss.Time,
+			MemHeapB:       ss.MemHeapB,
+			MemHeapExtraB:  ss.MemHeapExtraB,
+			MemStacksB:     ss.MemStacksB,
+			StacksProfiled: ss.StacksProfiled,
+			HeapTree:       ss.HeapTree.convert(),
+			IsPeak:         ss.IsPeak,
+		}
+		if intern {
+			log.Frames.InternTree(log.Snapshots[i].HeapTree)
+		} else {
+			log.Frames.Share(log.Snapshots[i].HeapTree)
+		}
+	}
+
+	if v0.Peak != nil {
+		log.Peak = *v0.Peak
+	} else {
+		log.DetectPeak()
+	}
+	return nil
+}
+
+func (ht *v0HeapTree) convert() *heaptree.HeapTree {
+	if ht == nil {
+		return nil
+	}
+
+	tree := &heaptree.HeapTree{
+		ID:      ht.ID,
+		Memory:  ht.Memory,
+		FrameID: ht.FrameID,
+		Frame:   &heaptree.Frame{Address: ht.Address, Func: ht.Func, FuncFullDesc: ht.FuncFullDesc},
+	}
+	for _, leaf := range ht.HeapAllocationLeafs {
+		tree.HeapAllocationLeafs = append(tree.HeapAllocationLeafs, leaf.convert())
+	}
+	return tree
+}
@@ -1,6 +1,11 @@
 package outlog
 
-import "github.com/MohamTahaB/massif-miner/internal/snapshot"
+import (
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
 
 // Define the OutLog that will serve as an accessible JSON parsing of the massif.out log files
 type OutLog struct {
@@ -8,4 +13,27 @@ type OutLog struct {
 	Cmd       string              `json:"cmd"`
 	TimeUnit  TimeUnit            `json:"timeUnit"`
 	Snapshots []snapshot.Snapshot `json:"snapshots"`
+	Peak      Peak                `json:"peak"`
+	// Whether massif profiled the stacks, see snapshot.Snapshot.Stacks
+	StacksProfiled bool `json:"stacksProfiled"`
+	// Distinct frames of the heap trees, referenced by their nodes
+	Frames heaptree.FrameTable `json:"frames"`
+}
+
+// Returns the value of a massif option from the log desc, e.g. "2.0" for "--peak-inaccuracy=2.0", and whether it was found.
+// The name is given without the leading dashes
+func (log *OutLog) Option(name string) (string, bool) {
+	prefix := "--" + name + "="
+	for _, field := range strings.Fields(log.Desc) {
+		if value, found := strings.CutPrefix(field, prefix); found {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Checks whether the log desc holds the --stacks=yes option massif needs to profile stacks
+func (log *OutLog) StacksOption() bool {
+	value, ok := log.Option("stacks")
+	return ok && value == "yes"
 }
@@ -0,0 +1,70 @@
+package outlog
+
+import "strconv"
+
+// Peak inaccuracy massif uses when --peak-inaccuracy is not specified, in percent
+const DefaultPeakInaccuracy = 1.0
+
+// Define the peak of a log, as flagged by massif and as computed from the snapshots totals (heap, extra heap and stacks).
+// Snapshot ids are set to -1 when there is no such snapshot
+type Peak struct {
+	// Snapshot massif flagged with heap_tree=peak, missing e.g. when the program crashed
+	FlaggedID    int `json:"flaggedId"`
+	FlaggedTotal int `json:"flaggedTotal"`
+
+	// Snapshot with the maximum total
+	MaxID    int `json:"maxId"`
+	MaxTotal int `json:"maxTotal"`
+
+	// Massif only records a new peak when it is larger than the previous one by the inaccuracy percentage.
+	// The true peak thus lies within [BandLow, BandHigh]
+	Inaccuracy float64 `json:"inaccuracy"`
+	BandLow    int     `json:"bandLow"`
+	BandHigh   int     `json:"bandHigh"`
+
+	// Whether the flagged peak is the snapshot with the maximum total
+	Consistent bool `json:"consistent"`
+}
+
+// Returns the peak inaccuracy of the log, from its desc or massif's default
+func (log *OutLog) PeakInaccuracy() float64 {
+	if value, ok := log.Option("peak-inaccuracy"); ok {
+		if inaccuracy, err := strconv.ParseFloat(value, 64); err == nil && inaccuracy >= 0 {
+			return inaccuracy
+		}
+	}
+	return DefaultPeakInaccuracy
+}
+
+// Computes the peak of the log from its snapshots, reconciles it with the one massif flagged, and sets it on the log
+func (log *OutLog) DetectPeak() Peak {
+	peak := Peak{
+		FlaggedID:  -1,
+		MaxID:      -1,
+		Inaccuracy: log.PeakInaccuracy(),
+	}
+
+	for _, ss := range log.Snapshots {
+		total := ss.MemHeapB + ss.MemHeapExtraB + ss.MemStacksB
+		if ss.IsPeak {
+			peak.FlaggedID = ss.Id
+			peak.FlaggedTotal = total
+		}
+		if peak.MaxID == -1 || total > peak.MaxTotal {
+			peak.MaxID = ss.Id
+			peak.MaxTotal = total
+		}
+	}
+
+	// Ties are settled in favor of the flagged peak
+	if peak.FlaggedID != -1 && peak.FlaggedTotal == peak.MaxTotal {
+		peak.MaxID = peak.FlaggedID
+	}
+
+	peak.Consistent = peak.FlaggedID != -1 && peak.FlaggedID == peak.MaxID
+	peak.BandLow = peak.MaxTotal
+	peak.BandHigh = peak.MaxTotal + int(float64(peak.MaxTotal)*peak.Inaccuracy/100)
+
+	log.Peak = peak
+	return peak
+}
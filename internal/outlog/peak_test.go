@@ -0,0 +1,69 @@
+package outlog
+
+import (
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+func TestDetectPeak_Consistent_OK(t *testing.T) {
+	log := OutLog{
+		Desc: "--massif-out-file=massif.out --peak-inaccuracy=2.5",
+		Snapshots: []snapshot.Snapshot{
+			{Id: 0, MemHeapB: 100},
+			{Id: 1, MemHeapB: 200, MemHeapExtraB: 8, IsPeak: true},
+			{Id: 2, MemHeapB: 150},
+		},
+	}
+
+	peak := log.DetectPeak()
+	if peak.FlaggedID != 1 || peak.MaxID != 1 || !peak.Consistent || peak.MaxTotal != 208 {
+		t.Fatalf("peak test error: unexpected peak %+v", peak)
+	}
+	if peak.Inaccuracy != 2.5 || peak.BandLow != 208 || peak.BandHigh != 213 {
+		t.Fatalf("peak test error: unexpected inaccuracy band %+v", peak)
+	}
+	if log.Peak != peak {
+		t.Fatal("peak test error: the peak is not set on the log")
+	}
+}
+
+func TestDetectPeak_Reconciled_OK(t *testing.T) {
+
+	// The flagged peak is within the inaccuracy of a later, larger snapshot
+	log := OutLog{
+		Snapshots: []snapshot.Snapshot{
+			{Id: 0, MemHeapB: 1000, IsPeak: true},
+			{Id: 1, MemHeapB: 1000, MemStacksB: 5},
+		},
+	}
+
+	peak := log.DetectPeak()
+	if peak.FlaggedID != 0 || peak.MaxID != 1 || peak.Consistent || peak.Inaccuracy != DefaultPeakInaccuracy || peak.BandHigh != 1015 {
+		t.Fatalf("peak test error: unexpected peak %+v", peak)
+	}
+
+	// No flagged peak, e.g. when the program crashed
+	log.Snapshots[0].IsPeak = false
+	peak = log.DetectPeak()
+	if peak.FlaggedID != -1 || peak.MaxID != 1 || peak.Consistent {
+		t.Fatalf("peak test error: unexpected peak %+v", peak)
+	}
+
+	// No snapshots at all
+	log.Snapshots = nil
+	if peak = log.DetectPeak(); peak.FlaggedID != -1 || peak.MaxID != -1 || peak.BandHigh != 0 {
+		t.Fatalf("peak test error: unexpected peak %+v", peak)
+	}
+}
+
+func TestOption_OK(t *testing.T) {
+	log := OutLog{Desc: "--stacks=yes --massif-out-file=massif.out.log"}
+
+	if value, ok := log.Option("stacks"); !ok || value != "yes" {
+		t.Fatalf("option test error: expected stacks=yes, found %s", value)
+	}
+	if _, ok := log.Option("peak-inaccuracy"); ok {
+		t.Fatal("option test error: peak-inaccuracy should not be found")
+	}
+}
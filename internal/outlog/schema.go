@@ -0,0 +1,62 @@
+package outlog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Version of the JSON encoding of the logs, written in their schemaVersion field and described by Schema.
+// Version 0 stands for the former unversioned encoding, with untagged heap trees and a numeric time unit
+const SchemaVersion = 1
+
+// JSON Schema of the current encoding of the logs
+//
+//go:embed outlog.schema.json
+var Schema []byte
+
+// Encodes the log along with the current schema version
+func (log OutLog) MarshalJSON() ([]byte, error) {
+	type plain OutLog
+	return json.Marshal(struct {
+		SchemaVersion int `json:"schemaVersion"`
+		plain
+	}{SchemaVersion, plain(log)})
+}
+
+// Decodes a log of the current schema version, or of an older one. Logs without a schemaVersion field are of version 0
+func (log *OutLog) UnmarshalJSON(data []byte) error {
+	var version struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return err
+	}
+
+	switch version.SchemaVersion {
+	case 0:
+		return log.unmarshalV0(data)
+	case SchemaVersion:
+		type plain OutLog
+		*log = OutLog{}
+		if err := json.Unmarshal(data, (*plain)(log)); err != nil {
+			return err
+		}
+		for i := range log.Snapshots {
+			log.Frames.Share(log.Snapshots[i].HeapTree)
+		}
+		return nil
+	default:
+		return fmt.Errorf("schema error: version %d not supported, the latest is %d", version.SchemaVersion, SchemaVersion)
+	}
+}
+
+// Reads a JSON encoded log, of any supported schema version
+func Decode(r io.Reader) (OutLog, error) {
+	var log OutLog
+	if err := json.NewDecoder(r).Decode(&log); err != nil {
+		return OutLog{}, fmt.Errorf("schema error: %w", err)
+	}
+	return log, nil
+}
@@ -0,0 +1,162 @@
+package outlog
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+func schemaTestLog() OutLog {
+	tree := &heaptree.HeapTree{ID: 1, Memory: 100, Frame: &heaptree.Frame{Address: "root", Func: "heap allocation functions", FuncFullDesc: "heap allocation functions"},
+		HeapAllocationLeafs: []*heaptree.HeapTree{{Memory: 100, Frame: &heaptree.Frame{Address: "0x1", Func: "main", Location: "a.c:1"}}},
+	}
+	log := OutLog{
+		Desc:     "--stacks=yes",
+		Cmd:      "./app",
+		TimeUnit: MS,
+		Snapshots: []snapshot.Snapshot{
+			{Id: 0, MemStacksB: 8, StacksProfiled: true},
+			{Id: 1, Time: 10, MemHeapB: 100, MemHeapExtraB: 8, MemStacksB: 16, StacksProfiled: true, HeapTree: tree, IsPeak: true},
+		},
+		StacksProfiled: true,
+	}
+	log.Frames.InternTree(tree)
+	log.DetectPeak()
+	return log
+}
+
+// Checks that the value follows the keys and types of the schema, resolving its local references
+func checkSchema(t *testing.T, root, schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved := root
+		for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			resolved = resolved[name].(map[string]any)
+		}
+		checkSchema(t, root, resolved, value, path)
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for key, item := range v {
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				t.Fatalf("schema test error: %s.%s is not in the schema", path, key)
+			}
+			checkSchema(t, root, property, item, path+"."+key)
+		}
+		required, _ := schema["required"].([]any)
+		for _, key := range required {
+			if _, ok := v[key.(string)]; !ok {
+				t.Fatalf("schema test error: %s.%s is required", path, key)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			checkSchema(t, root, schema["items"].(map[string]any), item, path+"[]")
+		}
+	case string:
+		if schema["type"] != "string" && schema["enum"] == nil {
+			t.Fatalf("schema test error: %s is not a string in the schema", path)
+		}
+	}
+}
+
+func TestSchema_OK(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("schema test error: invalid schema: %v", err)
+	}
+
+	encoded, err := json.Marshal(schemaTestLog())
+	if err != nil {
+		t.Fatalf("schema test error: %v", err)
+	}
+	var value map[string]any
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		t.Fatalf("schema test error: %v", err)
+	}
+
+	if value["schemaVersion"] != float64(SchemaVersion) || value["timeUnit"] != "ms" {
+		t.Fatalf("schema test error: unexpected encoding %s", encoded)
+	}
+	checkSchema(t, schema, schema, value, "$")
+
+	// Every property of the schema is encoded, save for the optional ones
+	var keys []string
+	for key := range schema["properties"].(map[string]any) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) != len(value) {
+		t.Fatalf("schema test error: the schema properties %v differ from the encoded ones", keys)
+	}
+}
+
+func TestUnmarshalJSON_RoundTrip_OK(t *testing.T) {
+	log := schemaTestLog()
+	encoded, err := json.Marshal(log)
+	if err != nil {
+		t.Fatalf("schema test error: %v", err)
+	}
+
+	decoded, err := Decode(strings.NewReader(string(encoded)))
+	if err != nil {
+		t.Fatalf("schema test error: %v", err)
+	}
+	reencoded, _ := json.Marshal(decoded)
+	if string(reencoded) != string(encoded) || decoded.Snapshots[1].HeapTree.HeapAllocationLeafs[0].FrameID != 2 {
+		t.Fatalf("schema test error: %s decoded as %s", encoded, reencoded)
+	}
+}
+
+func TestUnmarshalJSON_V0_OK(t *testing.T) {
+
+	// A version 0 log written before the peak was detected and the frames were interned
+	v0 := `{"desc":"","cmd":"./app","timeUnit":2,"snapshots":[
+		{"id":0,"time":0,"memHeapB":0,"memHeapExtraB":0,"memStacks":0,"HeapTree":null,"IsPeak":false},
+		{"id":1,"time":10,"memHeapB":100,"memHeapExtraB":8,"memStacks":0,"IsPeak":true,"HeapTree":
+			{"ID":1,"Memory":100,"Address":"root","Func":"heap allocation functions","FuncFullDesc":"heap allocation functions","HeapAllocationLeafs":[
+				{"ID":0,"Memory":100,"Address":"0x1","Func":"main (a.c:1)","FuncFullDesc":"","HeapAllocationLeafs":null}]}}]}`
+
+	log, err := Decode(strings.NewReader(v0))
+	if err != nil {
+		t.Fatalf("schema test error: %v", err)
+	}
+
+	if log.TimeUnit != MS || len(log.Snapshots) != 2 || !log.Snapshots[1].IsPeak {
+		t.Fatalf("schema test error: unexpected log %+v", log)
+	}
+	if log.Peak.FlaggedID != 1 || log.Peak.MaxTotal != 108 || !log.Peak.Consistent {
+		t.Fatalf("schema test error: unexpected peak %+v", log.Peak)
+	}
+	leaf := log.Snapshots[1].HeapTree.HeapAllocationLeafs[0]
+	if log.Frames.Len() != 2 || leaf.FrameID != 2 || leaf.Func != "main (a.c:1)" {
+		t.Fatalf("schema test error: unexpected frames %+v", log.Frames)
+	}
+}
+
+func TestUnmarshalJSON_KO(t *testing.T) {
+	inputs := []string{
+		`{"schemaVersion":2,"snapshots":[]}`,
+		`{"schemaVersion":1,"timeUnit":"s"}`,
+		`{"schemaVersion":1,"timeUnit":"auto"}`,
+		`{"schemaVersion":1,"timeUnit":0}`,
+		// Version 0 logs with the AUTO time unit or an unknown one
+		`{"desc":"","cmd":"./app","timeUnit":3,"snapshots":[]}`,
+		`{"desc":"","cmd":"./app","timeUnit":7,"snapshots":[]}`,
+		`{"schemaVersion":"1"}`,
+		`[]`,
+	}
+
+	for _, input := range inputs {
+		if _, err := Decode(strings.NewReader(input)); err == nil {
+			t.Fatalf("schema test error: %s decoded without error", input)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package outlog
 
+import (
+	"errors"
+	"fmt"
+)
+
 // Define time units accepted by Massif
 
 type TimeUnit int
@@ -8,5 +13,64 @@ const (
 	I TimeUnit = iota
 	B
 	MS
+	// AUTO is only a massif option, massif resolves it to one of the units above before writing the log
 	AUTO
 )
+
+// Returned when parsing the AUTO time unit, which massif never writes in a log
+var ErrUnresolvedTimeUnit = errors.New("time unit auto is unresolved, massif writes logs in i, B or ms")
+
+// Parses a time unit as written in the time_unit line of a massif log. AUTO is rejected with ErrUnresolvedTimeUnit, as it is not the unit of any log
+func ParseTimeUnit(s string) (TimeUnit, error) {
+	switch s {
+	case "i":
+		return I, nil
+	case "B":
+		return B, nil
+	case "ms":
+		return MS, nil
+	case "auto":
+		return AUTO, ErrUnresolvedTimeUnit
+	default:
+		return AUTO, fmt.Errorf("time unit %s not supported", s)
+	}
+}
+
+// Returns the time unit as written in a massif log
+func (tu TimeUnit) String() string {
+	switch tu {
+	case I:
+		return "i"
+	case B:
+		return "B"
+	case MS:
+		return "ms"
+	case AUTO:
+		return "auto"
+	default:
+		return fmt.Sprintf("TimeUnit(%d)", int(tu))
+	}
+}
+
+// Checks whether the time unit is one massif writes logs in, i.e. not AUTO
+func (tu TimeUnit) IsResolved() bool {
+	return tu == I || tu == B || tu == MS
+}
+
+// Encodes the time unit as written in a massif log, e.g. "ms". Unresolved time units are rejected
+func (tu TimeUnit) MarshalText() ([]byte, error) {
+	if !tu.IsResolved() {
+		return nil, fmt.Errorf("time unit %s not supported", tu)
+	}
+	return []byte(tu.String()), nil
+}
+
+// Decodes a time unit encoded by MarshalText
+func (tu *TimeUnit) UnmarshalText(text []byte) error {
+	parsed, err := ParseTimeUnit(string(text))
+	if err != nil {
+		return err
+	}
+	*tu = parsed
+	return nil
+}
@@ -0,0 +1,168 @@
+package overhead
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/utils"
+)
+
+// Massif defaults for the bytes of administrative overhead per block (--heap-admin) and the block alignment (--alignment)
+const (
+	DefaultHeapAdmin = 8
+	DefaultAlignment = 16
+)
+
+// Average block size under which a workload is considered dominated by tiny allocations, in bytes
+const TinyBlockSize = 64
+
+// Minimum useful heap for a snapshot to be considered when looking for spikes, so that the few bytes of the program start and end do not skew the analysis
+const MinSpikeHeap = 4096
+
+// Define the allocator overhead of a snapshot
+type SnapshotOverhead struct {
+	SnapshotID    int `json:"snapshotId"`
+	Time          int `json:"time"`
+	MemHeapB      int `json:"memHeapB"`
+	MemHeapExtraB int `json:"memHeapExtraB"`
+	// Extra over useful heap bytes
+	Ratio float64 `json:"ratio"`
+	// Estimated number of live blocks, from the extra heap bytes and the estimated overhead per block
+	EstimatedBlocks int `json:"estimatedBlocks"`
+	// Estimated average size of the live blocks, in bytes
+	AvgBlockSize float64 `json:"avgBlockSize"`
+	// Whether the ratio of this snapshot is way above the rest of the run
+	Spike bool `json:"spike"`
+}
+
+// Define the allocator overhead of a whole run
+type Report struct {
+	Snapshots []SnapshotOverhead `json:"snapshots"`
+
+	// Extra over useful heap bytes, summed over all the snapshots
+	Ratio float64 `json:"ratio"`
+	// Highest ratio of the run, and the snapshot it was reached in
+	PeakRatio           float64 `json:"peakRatio"`
+	PeakRatioSnapshotID int     `json:"peakRatioSnapshotId"`
+	// Least squares growth of the ratio, per time unit of the log
+	Trend float64 `json:"trend"`
+
+	// Estimated overhead per allocated block, from the massif heap admin and alignment options
+	OverheadPerBlock float64 `json:"overheadPerBlock"`
+	// Estimated average size of the live blocks over the run, in bytes
+	AvgBlockSize float64 `json:"avgBlockSize"`
+	// Whether the run is dominated by tiny allocations
+	TinyAllocations bool `json:"tinyAllocations"`
+
+	// Ids of the snapshots where the overhead spikes
+	Spikes []int `json:"spikes"`
+}
+
+// Returns the estimated overhead of a block: the administrative bytes, plus half the alignment on average
+func OverheadPerBlock(log *outlog.OutLog) float64 {
+	heapAdmin, alignment := DefaultHeapAdmin, DefaultAlignment
+	if value, ok := log.Option("heap-admin"); ok {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			heapAdmin = parsed
+		}
+	}
+	if value, ok := log.Option("alignment"); ok {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			alignment = parsed
+		}
+	}
+
+	return float64(heapAdmin) + float64(alignment)/2
+}
+
+// Analyzes the allocator overhead of every snapshot of the log, and of the run as a whole.
+// Spikes are the snapshots holding at least MinSpikeHeap bytes whose ratio is more than two standard deviations above the mean
+func Analyze(log *outlog.OutLog) Report {
+	report := Report{
+		Snapshots:           make([]SnapshotOverhead, 0, len(log.Snapshots)),
+		PeakRatioSnapshotID: -1,
+		OverheadPerBlock:    OverheadPerBlock(log),
+		Spikes:              []int{},
+	}
+
+	var sumHeap, sumExtra, blocks int
+	var times, ratios []float64
+
+	for _, ss := range log.Snapshots {
+		so := SnapshotOverhead{
+			SnapshotID:      ss.Id,
+			Time:            ss.Time,
+			MemHeapB:        ss.MemHeapB,
+			MemHeapExtraB:   ss.MemHeapExtraB,
+			EstimatedBlocks: int(math.Round(float64(ss.MemHeapExtraB) / report.OverheadPerBlock)),
+		}
+
+		// A live heap means at least one block
+		if so.EstimatedBlocks == 0 && ss.MemHeapB > 0 {
+			so.EstimatedBlocks = 1
+		}
+		if ss.MemHeapB > 0 {
+			so.Ratio = float64(ss.MemHeapExtraB) / float64(ss.MemHeapB)
+			so.AvgBlockSize = float64(ss.MemHeapB) / float64(so.EstimatedBlocks)
+
+			times = append(times, float64(ss.Time))
+			ratios = append(ratios, so.Ratio)
+		}
+
+		if report.PeakRatioSnapshotID == -1 || so.Ratio > report.PeakRatio {
+			report.PeakRatio = so.Ratio
+			report.PeakRatioSnapshotID = so.SnapshotID
+		}
+
+		sumHeap += ss.MemHeapB
+		sumExtra += ss.MemHeapExtraB
+		blocks += so.EstimatedBlocks
+		report.Snapshots = append(report.Snapshots, so)
+	}
+
+	if sumHeap > 0 {
+		report.Ratio = float64(sumExtra) / float64(sumHeap)
+	}
+	if blocks > 0 {
+		report.AvgBlockSize = float64(sumHeap) / float64(blocks)
+		report.TinyAllocations = report.AvgBlockSize < TinyBlockSize
+	}
+	report.Trend = utils.LeastSquaresSlope(times, ratios)
+
+	// Look for spikes among the snapshots with a significant heap
+	var significant []float64
+	for _, so := range report.Snapshots {
+		if so.MemHeapB >= MinSpikeHeap {
+			significant = append(significant, so.Ratio)
+		}
+	}
+	mean, stdDev := meanStdDev(significant)
+	for i, so := range report.Snapshots {
+		if so.MemHeapB >= MinSpikeHeap && stdDev > 0 && so.Ratio > mean+2*stdDev {
+			report.Snapshots[i].Spike = true
+			report.Spikes = append(report.Spikes, so.SnapshotID)
+		}
+	}
+
+	return report
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
@@ -0,0 +1,81 @@
+package overhead
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("overhead test error: error reading from the massif.out: %v", err)
+	}
+	return log
+}
+
+func TestAnalyze_SpikeTiny_OK(t *testing.T) {
+	ol := outlog.OutLog{Desc: "--heap-admin=16 --alignment=16"}
+	for i := 0; i < 10; i++ {
+		ol.Snapshots = append(ol.Snapshots, snapshot.Snapshot{Id: i, Time: i * 100, MemHeapB: 10000, MemHeapExtraB: 1000})
+	}
+
+	// Tiny allocations blow the overhead up in a single snapshot
+	ol.Snapshots[6].MemHeapExtraB = 9600
+
+	report := Analyze(&ol)
+	if report.OverheadPerBlock != 24 {
+		t.Fatalf("overhead test error: expected 24 bytes of overhead per block, found %f", report.OverheadPerBlock)
+	}
+	if len(report.Spikes) != 1 || report.Spikes[0] != 6 || !report.Snapshots[6].Spike {
+		t.Fatalf("overhead test error: expected a spike at snapshot 6, found %v", report.Spikes)
+	}
+	if report.PeakRatioSnapshotID != 6 || report.PeakRatio != 0.96 {
+		t.Fatalf("overhead test error: unexpected peak ratio %f at %d", report.PeakRatio, report.PeakRatioSnapshotID)
+	}
+	if report.Snapshots[6].EstimatedBlocks != 400 || report.Snapshots[6].AvgBlockSize != 25 {
+		t.Fatalf("overhead test error: unexpected estimates %+v", report.Snapshots[6])
+	}
+	if report.TinyAllocations {
+		t.Fatalf("overhead test error: the run should not be dominated by tiny allocations, average block size %f", report.AvgBlockSize)
+	}
+
+	// Make every snapshot look like snapshot 6
+	for i := range ol.Snapshots {
+		ol.Snapshots[i].MemHeapExtraB = 9600
+	}
+	report = Analyze(&ol)
+	if !report.TinyAllocations || len(report.Spikes) != 0 || report.Trend != 0 {
+		t.Fatalf("overhead test error: unexpected report for a tiny allocations workload %+v", report)
+	}
+}
+
+func TestAnalyzeOnMassifLog_OK(t *testing.T) {
+
+	ol := parseArtifact(t)
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	report := Analyze(&ol)
+	if len(report.Snapshots) != 60 || report.OverheadPerBlock != 16 {
+		t.Fatalf("overhead test error: unexpected report with %d snapshots", len(report.Snapshots))
+	}
+	if report.PeakRatioSnapshotID != 41 || report.TinyAllocations || len(report.Spikes) != 0 {
+		t.Fatalf("overhead test error: unexpected whole run metrics, peak ratio at %d, spikes %v", report.PeakRatioSnapshotID, report.Spikes)
+	}
+
+	// Snapshot 0 has an empty heap
+	if report.Snapshots[0].Ratio != 0 || report.Snapshots[0].EstimatedBlocks != 0 {
+		t.Fatalf("overhead test error: unexpected empty snapshot metrics %+v", report.Snapshots[0])
+	}
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/resample"
+	"github.com/MohamTahaB/massif-miner/internal/timeline"
+)
+
+// Margins of the chart plot area, leaving room for the axes labels and the legend
+const (
+	marginLeft   = 70
+	marginRight  = 20
+	marginTop    = 30
+	marginBottom = 40
+)
+
+// Define a stacked layer of the chart, from the bottom one up
+type layer struct {
+	name  string
+	color string
+	bytes func(p timeline.Point) int
+}
+
+var layers = []layer{
+	{"heap", "#4e79a7", func(p timeline.Point) int { return p.MemHeapB }},
+	{"extra heap", "#f28e2b", func(p timeline.Point) int { return p.MemHeapExtraB }},
+	{"stacks", "#59a14f", func(p timeline.Point) int { return p.MemStacksB }},
+}
+
+// Draws the memory of the log over time as a standalone SVG element, heap, extra heap and stacks being stacked up.
+// The peak is marked with a dashed line, and the detailed snapshots with dots. The SVG has no external references, so that it can be inlined anywhere
+func Chart(log *outlog.OutLog, width, height int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`, width, height, width, height)
+	fmt.Fprintf(&b, `<title>Memory of %s</title>`, html.EscapeString(log.Cmd))
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`, width, height)
+
+	tl := timeline.Build(log)
+	maxTotal := 0
+	for _, p := range tl.Points {
+		maxTotal = max(maxTotal, p.Total)
+	}
+
+	plotWidth := float64(width - marginLeft - marginRight)
+	plotHeight := float64(height - marginTop - marginBottom)
+	if len(tl.Points) == 0 || maxTotal == 0 || plotWidth <= 0 || plotHeight <= 0 {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">no memory recorded</text></svg>`, width/2, height/2)
+		return b.String()
+	}
+
+	x := func(p timeline.Point) float64 {
+		return marginLeft + plotWidth*p.Position/100
+	}
+	y := func(bytes int) float64 {
+		return marginTop + plotHeight*(1-float64(bytes)/float64(maxTotal))
+	}
+
+	// Stack the layers, each one drawn from the top of the previous one
+	base := make([]int, len(tl.Points))
+	for _, l := range layers {
+		var points strings.Builder
+		top := make([]int, len(tl.Points))
+		for i, p := range tl.Points {
+			top[i] = base[i] + l.bytes(p)
+			fmt.Fprintf(&points, "%.1f,%.1f ", x(p), y(top[i]))
+		}
+		for i := len(tl.Points) - 1; i >= 0; i-- {
+			fmt.Fprintf(&points, "%.1f,%.1f ", x(tl.Points[i]), y(base[i]))
+		}
+		fmt.Fprintf(&b, `<polygon points="%s" fill="%s" fill-opacity="0.8"><title>%s</title></polygon>`, strings.TrimSpace(points.String()), l.color, l.name)
+		base = top
+	}
+
+	// Axes, with the byte scale on the left and the time span below
+	fmt.Fprintf(&b, `<g stroke="#333333"><line x1="%d" y1="%d" x2="%d" y2="%.1f"/><line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f"/></g>`,
+		marginLeft, marginTop, marginLeft, y(0), marginLeft, y(0), marginLeft+plotWidth, y(0))
+	for _, fraction := range []int{0, 2, 4} {
+		bytes := maxTotal * fraction / 4
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`, marginLeft-6, y(bytes), timeline.FormatBytes(bytes))
+	}
+	first, last := tl.Points[0], tl.Points[len(tl.Points)-1]
+	fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="start">%s</text>`, x(first), y(0)+18, html.EscapeString(first.Label))
+	fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end">%s</text>`, x(last), y(0)+18, html.EscapeString(last.Label))
+
+	// Peak and detailed snapshots
+	for _, p := range tl.Points {
+		if p.SnapshotID == log.Peak.MaxID {
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#e15759" stroke-dasharray="4 3"/>`, x(p), marginTop, x(p), y(0))
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" fill="#e15759">peak %s</text>`, x(p), marginTop-6, timeline.FormatBytes(p.Total))
+		}
+		if p.Detailed {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="#333333"><title>snapshot %d, %s</title></circle>`, x(p), y(p.Total), p.SnapshotID, timeline.FormatBytes(p.Total))
+		}
+	}
+
+	// Legend, in the top left corner of the plot area
+	for i, l := range layers {
+		lx := marginLeft + 10 + i*100
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/><text x="%d" y="%d">%s</text>`, lx, marginTop+6, l.color, lx+14, marginTop+15, l.name)
+	}
+
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// Draws the total memory of the log over time as a Mermaid xychart, which GitHub renders in Markdown, PR comments included, unlike inline SVG.
+// The snapshots are resampled down to about points of them, the chart being drawn with evenly spaced snapshots
+func MermaidChart(log *outlog.OutLog, points int) string {
+	resampled := *log
+	resampled.Snapshots = resample.Resample(log.Snapshots, points, resample.LTTB)
+	tl := timeline.Build(&resampled)
+
+	quote := strings.NewReplacer(`"`, "'", "`", "'", "\n", " ")
+	var b strings.Builder
+	b.WriteString("```mermaid\nxychart-beta\n")
+	fmt.Fprintf(&b, "    title \"Memory of %s, peak %s\"\n", quote.Replace(log.Cmd), timeline.FormatBytes(log.Peak.MaxTotal))
+	if len(tl.Points) == 0 {
+		b.WriteString("    x-axis [\"no memory recorded\"]\n    line [0]\n```")
+		return b.String()
+	}
+
+	labels := make([]string, len(tl.Points))
+	totals := make([]string, len(tl.Points))
+	for i, p := range tl.Points {
+		labels[i] = fmt.Sprintf("%q", quote.Replace(p.Label))
+		totals[i] = fmt.Sprint(p.Total)
+	}
+	fmt.Fprintf(&b, "    x-axis [%s]\n", strings.Join(labels, ", "))
+	b.WriteString("    y-axis \"total memory (B)\"\n")
+	fmt.Fprintf(&b, "    line [%s]\n```", strings.Join(totals, ", "))
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+	"github.com/MohamTahaB/massif-miner/internal/timeline"
+	"github.com/MohamTahaB/massif-miner/internal/top"
+)
+
+// Number of sites listed in the top and growth sections when not specified
+const DefaultSites = 10
+
+// Size of the memory chart, in pixels
+const (
+	ChartWidth  = 800
+	ChartHeight = 300
+)
+
+// Number of snapshots drawn in the Markdown memory chart, about
+const MarkdownChartPoints = 40
+
+// Define the summary report of a log, e.g. for pull request comments
+type Report struct {
+	Cmd      string
+	Desc     string
+	TimeUnit string
+
+	Snapshots int
+	Detailed  int
+	Peak      outlog.Peak
+	// Time and breakdown of the peak, i.e. the snapshot with the maximum total
+	PeakTime          string
+	PeakMemHeapB      int
+	PeakMemHeapExtraB int
+	PeakMemStacksB    int
+	StacksProfiled    bool
+
+	// Memory chart of the run, as a standalone SVG element for the HTML report, and as a Mermaid block for the Markdown one
+	Chart         string
+	MarkdownChart string
+
+	// Functions allocating the most at the peak, missing when the log has no detailed snapshot
+	PeakSnapshotID int
+	TopSites       []top.Site
+
+	// Functions growing the most between the first and last detailed snapshots, missing when there are less than two
+	FirstDetailedID int
+	LastDetailedID  int
+	Growth          []top.Delta
+}
+
+// Builds the report of the log, listing up to n sites in the top and growth sections, DefaultSites when not positive
+func Build(log *outlog.OutLog, n int) Report {
+	if n <= 0 {
+		n = DefaultSites
+	}
+
+	report := Report{
+		Cmd:             log.Cmd,
+		Desc:            log.Desc,
+		TimeUnit:        log.TimeUnit.String(),
+		Snapshots:       len(log.Snapshots),
+		Peak:            log.Peak,
+		StacksProfiled:  log.StacksProfiled,
+		Chart:           Chart(log, ChartWidth, ChartHeight),
+		MarkdownChart:   MermaidChart(log, MarkdownChartPoints),
+		PeakSnapshotID:  -1,
+		FirstDetailedID: -1,
+		LastDetailedID:  -1,
+	}
+
+	var first, last, peak *snapshot.Snapshot
+	for i := range log.Snapshots {
+		ss := &log.Snapshots[i]
+		if ss.Id == log.Peak.MaxID {
+			report.PeakTime = timeline.Time{Raw: ss.Time, Unit: log.TimeUnit}.String()
+			report.PeakMemHeapB, report.PeakMemHeapExtraB, report.PeakMemStacksB = ss.MemHeapB, ss.MemHeapExtraB, ss.MemStacksB
+		}
+		if ss.HeapTree == nil {
+			continue
+		}
+
+		report.Detailed++
+		if first == nil {
+			first = ss
+		}
+		last = ss
+		// The flagged peak is detailed, the largest detailed snapshot stands for it otherwise
+		if ss.Id == log.Peak.FlaggedID || (log.Peak.FlaggedID == -1 && (peak == nil || total(ss) > total(peak))) {
+			peak = ss
+		}
+	}
+
+	if peak != nil {
+		report.PeakSnapshotID = peak.Id
+		report.TopSites = top.TopN(peak, n)
+	}
+	if first != nil && first != last {
+		report.FirstDetailedID, report.LastDetailedID = first.Id, last.Id
+		for _, delta := range top.Diff(first, last) {
+			if delta.Delta > 0 && len(report.Growth) < n {
+				report.Growth = append(report.Growth, delta)
+			}
+		}
+	}
+
+	return report
+}
+
+func total(ss *snapshot.Snapshot) int {
+	return ss.MemHeapB + ss.MemHeapExtraB + ss.MemStacksB
+}
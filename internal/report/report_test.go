@@ -0,0 +1,120 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+)
+
+func parseArtifact(t *testing.T) outlog.OutLog {
+	// Open the massif.out log in the artifacts
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("report test error: %v", err)
+	}
+	return log
+}
+
+// Checks that the input is well formed XML
+func checkXML(t *testing.T, input string) {
+	decoder := xml.NewDecoder(strings.NewReader(input))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("report test error: malformed XML: %v", err)
+		}
+	}
+}
+
+func TestBuildOnMassifLog_OK(t *testing.T) {
+
+	log := parseArtifact(t)
+	report := Build(&log, 3)
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if report.Snapshots != 60 || report.Detailed != 8 || report.PeakSnapshotID != 45 || report.PeakMemHeapB != 165527 {
+		t.Fatalf("report test error: unexpected report %+v", report)
+	}
+	if len(report.TopSites) != 3 || report.TopSites[0].Func != "allocateAndDeallocate()" {
+		t.Fatalf("report test error: unexpected top sites %+v", report.TopSites)
+	}
+	if report.FirstDetailedID != 4 || report.LastDetailedID != 58 || len(report.Growth) != 3 || report.Growth[0].Delta != 29697 {
+		t.Fatalf("report test error: unexpected growth from %d to %d: %+v", report.FirstDetailedID, report.LastDetailedID, report.Growth)
+	}
+
+	checkXML(t, report.Chart)
+	if strings.Count(report.Chart, "<circle") != 8 || !strings.Contains(report.Chart, "peak 165 KiB") {
+		t.Fatalf("report test error: unexpected chart %s", report.Chart)
+	}
+}
+
+func TestWrite_OK(t *testing.T) {
+
+	log := parseArtifact(t)
+	log.Cmd = "./app <script>alert(1)</script> | tee `x`"
+	report := Build(&log, 0)
+
+	var markdown bytes.Buffer
+	if err := WriteMarkdown(&markdown, report); err != nil {
+		t.Fatalf("report test error: %v", err)
+	}
+	for _, expected := range []string{"## Peak", "| 165527 | 3017 | n/a | 168544 |", "## Largest growth", "\\| tee 'x'", "```mermaid\nxychart-beta\n", "168544"} {
+		if !strings.Contains(markdown.String(), expected) {
+			t.Fatalf("report test error: %q not found in the markdown report", expected)
+		}
+	}
+	// GitHub strips inline SVG from Markdown, the SVG chart is only in the HTML report
+	if strings.Contains(markdown.String(), "<svg") {
+		t.Fatal("report test error: inline SVG in the markdown report")
+	}
+
+	var page bytes.Buffer
+	if err := WriteHTML(&page, report); err != nil {
+		t.Fatalf("report test error: %v", err)
+	}
+	if !strings.Contains(page.String(), "<svg") || strings.Contains(page.String(), "mermaid") {
+		t.Fatal("report test error: the HTML report does not hold the SVG chart")
+	}
+	if strings.Contains(page.String(), "<script>") || !strings.Contains(page.String(), "&lt;script&gt;") {
+		t.Fatal("report test error: the command is not escaped in the HTML report")
+	}
+
+	// No external assets: the only URL is the SVG namespace
+	if strings.Contains(page.String(), "src=") || strings.Contains(page.String(), "href=") || strings.Count(page.String(), "http") != 1 {
+		t.Fatal("report test error: the HTML report references external assets")
+	}
+}
+
+func TestBuild_Empty_OK(t *testing.T) {
+
+	log := outlog.OutLog{Cmd: "./app"}
+	log.DetectPeak()
+	report := Build(&log, 0)
+
+	if report.PeakSnapshotID != -1 || report.FirstDetailedID != -1 || !strings.Contains(report.Chart, "no memory recorded") {
+		t.Fatalf("report test error: unexpected report %+v", report)
+	}
+	checkXML(t, report.Chart)
+
+	var markdown, page bytes.Buffer
+	if err := WriteMarkdown(&markdown, report); err != nil || !strings.Contains(markdown.String(), "No snapshots.") {
+		t.Fatalf("report test error: unexpected markdown report, %v", err)
+	}
+	if err := WriteHTML(&page, report); err != nil || !strings.Contains(page.String(), "Less than two detailed snapshots.") {
+		t.Fatalf("report test error: unexpected HTML report, %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/timeline"
+)
+
+// Writes the report as GitHub flavored Markdown. The chart is a Mermaid block, rendered by GitHub in PR comments, where inline SVG is stripped
+func WriteMarkdown(w io.Writer, report Report) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Massif report: `%s`\n\n", markdownCode(report.Cmd))
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Command | `%s` |\n", markdownCode(report.Cmd))
+	fmt.Fprintf(&b, "| Description | `%s` |\n", markdownCode(report.Desc))
+	fmt.Fprintf(&b, "| Time unit | %s |\n", report.TimeUnit)
+	fmt.Fprintf(&b, "| Snapshots | %d (%d detailed) |\n\n", report.Snapshots, report.Detailed)
+
+	b.WriteString("## Peak\n\n")
+	if report.Peak.MaxID == -1 {
+		b.WriteString("No snapshots.\n\n")
+	} else {
+		fmt.Fprintf(&b, "**%s** at snapshot %d (%s)\n\n", timeline.FormatBytes(report.Peak.MaxTotal), report.Peak.MaxID, report.PeakTime)
+		b.WriteString("| Heap | Extra heap | Stacks | Total |\n|---:|---:|---:|---:|\n")
+		fmt.Fprintf(&b, "| %d | %d | %s | %d |\n\n", report.PeakMemHeapB, report.PeakMemHeapExtraB, report.stacks(), report.Peak.MaxTotal)
+		if note := report.peakNote(); note != "" {
+			fmt.Fprintf(&b, "> %s\n\n", note)
+		}
+	}
+
+	fmt.Fprintf(&b, "%s\n\n", report.MarkdownChart)
+
+	b.WriteString("## Top allocation sites at the peak\n\n")
+	if report.PeakSnapshotID == -1 {
+		b.WriteString("No detailed snapshot.\n\n")
+	} else {
+		fmt.Fprintf(&b, "Snapshot %d.\n\n", report.PeakSnapshotID)
+		b.WriteString("| Inclusive | Self | % of heap | Function |\n|---:|---:|---:|---|\n")
+		for _, site := range report.TopSites {
+			fmt.Fprintf(&b, "| %d | %d | %.2f%% | `%s` |\n", site.InclusiveBytes, site.SelfBytes, site.Percent, markdownCode(site.Func))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Largest growth\n\n")
+	switch {
+	case report.FirstDetailedID == -1:
+		b.WriteString("Less than two detailed snapshots.\n")
+	case len(report.Growth) == 0:
+		fmt.Fprintf(&b, "No function grew between snapshots %d and %d.\n", report.FirstDetailedID, report.LastDetailedID)
+	default:
+		fmt.Fprintf(&b, "From snapshot %d to snapshot %d.\n\n| Before | After | Growth | Function |\n|---:|---:|---:|---|\n", report.FirstDetailedID, report.LastDetailedID)
+		for _, delta := range report.Growth {
+			fmt.Fprintf(&b, "| %d | %d | +%d | `%s` |\n", delta.Before, delta.After, delta.Delta, markdownCode(delta.Func))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Writes the report as a single HTML page, with its style and chart inlined, so that it can be viewed offline
+func WriteHTML(w io.Writer, report Report) error {
+	return htmlTemplate.Execute(w, struct {
+		Report
+		ChartHTML template.HTML
+		PeakB     string
+		Stacks    string
+		PeakNote  string
+	}{
+		Report: report,
+		// The chart is built out of escaped text only
+		ChartHTML: template.HTML(report.Chart),
+		PeakB:     timeline.FormatBytes(report.Peak.MaxTotal),
+		Stacks:    report.stacks(),
+		PeakNote:  report.peakNote(),
+	})
+}
+
+// Returns the stack bytes at the peak, or n/a when massif did not profile them
+func (report Report) stacks() string {
+	if !report.StacksProfiled {
+		return "n/a"
+	}
+	return fmt.Sprint(report.PeakMemStacksB)
+}
+
+// Returns a note when the flagged peak is missing or is not the actual one, and an empty string otherwise
+func (report Report) peakNote() string {
+	switch {
+	case report.Peak.MaxID == -1 || report.Peak.Consistent:
+		return ""
+	case report.Peak.FlaggedID == -1:
+		return "Massif flagged no peak, e.g. as the program did not exit normally."
+	default:
+		return fmt.Sprintf("Massif flagged snapshot %d (%s) as the peak, within its %.1f%% peak inaccuracy.",
+			report.Peak.FlaggedID, timeline.FormatBytes(report.Peak.FlaggedTotal), report.Peak.Inaccuracy)
+	}
+}
+
+// Makes the text safe for a Markdown code span in a table cell
+func markdownCode(s string) string {
+	return strings.NewReplacer("`", "'", "|", "\\|", "\n", " ").Replace(s)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Massif report: {{.Cmd}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #222222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #dddddd; padding: 4px 8px; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+code { font-size: 90%; word-break: break-all; }
+blockquote { color: #8a4b08; }
+</style>
+</head>
+<body>
+<h1>Massif report: <code>{{.Cmd}}</code></h1>
+<table>
+<tr><th>Command</th><td><code>{{.Cmd}}</code></td></tr>
+<tr><th>Description</th><td><code>{{.Desc}}</code></td></tr>
+<tr><th>Time unit</th><td>{{.TimeUnit}}</td></tr>
+<tr><th>Snapshots</th><td>{{.Snapshots}} ({{.Detailed}} detailed)</td></tr>
+</table>
+
+<h2>Peak</h2>
+{{if eq .Peak.MaxID -1}}<p>No snapshots.</p>{{else}}
+<p><strong>{{.PeakB}}</strong> at snapshot {{.Peak.MaxID}} ({{.PeakTime}})</p>
+<table>
+<tr><th>Heap</th><th>Extra heap</th><th>Stacks</th><th>Total</th></tr>
+<tr><td class="num">{{.PeakMemHeapB}}</td><td class="num">{{.PeakMemHeapExtraB}}</td><td class="num">{{.Stacks}}</td><td class="num">{{.Peak.MaxTotal}}</td></tr>
+</table>
+{{with .PeakNote}}<blockquote>{{.}}</blockquote>{{end}}{{end}}
+{{.ChartHTML}}
+
+<h2>Top allocation sites at the peak</h2>
+{{if eq .PeakSnapshotID -1}}<p>No detailed snapshot.</p>{{else}}
+<p>Snapshot {{.PeakSnapshotID}}.</p>
+<table>
+<tr><th>Inclusive</th><th>Self</th><th>% of heap</th><th>Function</th></tr>
+{{range .TopSites}}<tr><td class="num">{{.InclusiveBytes}}</td><td class="num">{{.SelfBytes}}</td><td class="num">{{printf "%.2f%%" .Percent}}</td><td><code>{{.Func}}</code></td></tr>
+{{end}}</table>{{end}}
+
+<h2>Largest growth</h2>
+{{if eq .FirstDetailedID -1}}<p>Less than two detailed snapshots.</p>{{else if not .Growth}}<p>No function grew between snapshots {{.FirstDetailedID}} and {{.LastDetailedID}}.</p>{{else}}
+<p>From snapshot {{.FirstDetailedID}} to snapshot {{.LastDetailedID}}.</p>
+<table>
+<tr><th>Before</th><th>After</th><th>Growth</th><th>Function</th></tr>
+{{range .Growth}}<tr><td class="num">{{.Before}}</td><td class="num">{{.After}}</td><td class="num">+{{.Delta}}</td><td><code>{{.Func}}</code></td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,158 @@
+package resample
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Define the algorithms snapshots can be downsampled with
+type Method string
+
+const (
+	// Largest-triangle-three-buckets, keeps the visual shape of the curve
+	LTTB Method = "lttb"
+	// Keeps the lowest and highest snapshot of every bucket, keeps the extremes of the curve
+	MinMax Method = "minmax"
+)
+
+// Parses a resampling method from its name, an empty name standing for LTTB
+func ParseMethod(s string) (Method, error) {
+	switch Method(s) {
+	case "", LTTB:
+		return LTTB, nil
+	case MinMax:
+		return MinMax, nil
+	default:
+		return "", fmt.Errorf("resample error: unknown method %s", s)
+	}
+}
+
+// Reduces the snapshots to about n points with the method, charting their total memory (heap, extra heap and stacks) over time.
+// The first and last snapshots, the peak (both the flagged one and the one with the maximum total) and every detailed snapshot are always kept, so the result might hold more than n snapshots when there are more of those.
+// The snapshots are returned in their original order, and are returned untouched when there are n or less
+func Resample(snapshots []snapshot.Snapshot, n int, method Method) []snapshot.Snapshot {
+	if n <= 0 || len(snapshots) <= n {
+		return snapshots
+	}
+
+	// Gather the snapshots that must be kept
+	keep := make(map[int]bool)
+	keep[0], keep[len(snapshots)-1] = true, true
+	maxIndex := 0
+	for i, ss := range snapshots {
+		if ss.IsPeak || ss.HeapTree != nil {
+			keep[i] = true
+		}
+		if total(ss) > total(snapshots[maxIndex]) {
+			maxIndex = i
+		}
+	}
+	keep[maxIndex] = true
+
+	// Spend the remaining budget on the method, which keeps the first and last snapshots as well
+	if budget := n - len(keep) + 2; budget > 2 {
+		var selected []int
+		switch method {
+		case MinMax:
+			selected = minMax(snapshots, budget)
+		default:
+			selected = lttb(snapshots, budget)
+		}
+		for _, i := range selected {
+			keep[i] = true
+		}
+	}
+
+	indexes := make([]int, 0, len(keep))
+	for i := range keep {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	result := make([]snapshot.Snapshot, 0, len(indexes))
+	for _, i := range indexes {
+		result = append(result, snapshots[i])
+	}
+	return result
+}
+
+// Selects the indexes of n snapshots with the largest-triangle-three-buckets algorithm, first and last included
+func lttb(snapshots []snapshot.Snapshot, n int) []int {
+	selected := []int{0}
+	bucketSize := float64(len(snapshots)-2) / float64(n-2)
+
+	previous := 0
+	for bucket := 0; bucket < n-2; bucket++ {
+		start := int(float64(bucket)*bucketSize) + 1
+		end := int(float64(bucket+1)*bucketSize) + 1
+
+		// Average point of the next bucket, the last snapshot standing for the one after the last bucket
+		nextStart, nextEnd := end, int(float64(bucket+2)*bucketSize)+1
+		if nextEnd > len(snapshots) {
+			nextEnd = len(snapshots)
+		}
+		if nextStart >= nextEnd {
+			nextStart, nextEnd = len(snapshots)-1, len(snapshots)
+		}
+		var avgX, avgY float64
+		for i := nextStart; i < nextEnd; i++ {
+			avgX += float64(snapshots[i].Time)
+			avgY += float64(total(snapshots[i]))
+		}
+		avgX /= float64(nextEnd - nextStart)
+		avgY /= float64(nextEnd - nextStart)
+
+		// Keep the snapshot forming the largest triangle with the previously selected one and the next bucket average
+		px, py := float64(snapshots[previous].Time), float64(total(snapshots[previous]))
+		best, bestArea := start, -1.0
+		for i := start; i < end; i++ {
+			area := math.Abs((px-avgX)*(float64(total(snapshots[i]))-py) - (px-float64(snapshots[i].Time))*(avgY-py))
+			if area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+
+		selected = append(selected, best)
+		previous = best
+	}
+
+	return append(selected, len(snapshots)-1)
+}
+
+// Selects the indexes of about n snapshots, the lowest and highest ones of n/2 buckets, first and last included
+func minMax(snapshots []snapshot.Snapshot, n int) []int {
+	selected := []int{0, len(snapshots) - 1}
+	buckets := (n - 2) / 2
+	if buckets == 0 {
+		buckets = 1
+	}
+
+	inner := len(snapshots) - 2
+	for bucket := 0; bucket < buckets; bucket++ {
+		start := bucket*inner/buckets + 1
+		end := (bucket+1)*inner/buckets + 1
+		if start >= end {
+			continue
+		}
+
+		low, high := start, start
+		for i := start; i < end; i++ {
+			if total(snapshots[i]) < total(snapshots[low]) {
+				low = i
+			}
+			if total(snapshots[i]) > total(snapshots[high]) {
+				high = i
+			}
+		}
+		selected = append(selected, low, high)
+	}
+
+	return selected
+}
+
+func total(ss snapshot.Snapshot) int {
+	return ss.MemHeapB + ss.MemHeapExtraB + ss.MemStacksB
+}
@@ -0,0 +1,111 @@
+package resample
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("resample test error: error reading from the massif.out: %v", err)
+	}
+	return log
+}
+
+// Builds a sine shaped series of snapshots, with a detailed one and a flagged peak
+func sineSnapshots(n int) []snapshot.Snapshot {
+	snapshots := make([]snapshot.Snapshot, n)
+	for i := range snapshots {
+		snapshots[i] = snapshot.Snapshot{Id: i, Time: i * 10, MemHeapB: 1000 + int(500*math.Sin(float64(i)/50))}
+	}
+	snapshots[123].HeapTree = &heaptree.HeapTree{}
+	snapshots[456].MemHeapB = 5000
+	snapshots[456].IsPeak = true
+	return snapshots
+}
+
+func TestResample_OK(t *testing.T) {
+	snapshots := sineSnapshots(1000)
+
+	for _, method := range []Method{LTTB, MinMax} {
+		resampled := Resample(snapshots, 50, method)
+		if len(resampled) > 50 || len(resampled) < 40 {
+			t.Fatalf("resample test error: expected about 50 snapshots with %s, found %d", method, len(resampled))
+		}
+
+		kept := make(map[int]bool)
+		for i, ss := range resampled {
+			if i > 0 && ss.Id <= resampled[i-1].Id {
+				t.Fatalf("resample test error: snapshots out of order with %s", method)
+			}
+			kept[ss.Id] = true
+		}
+		for _, id := range []int{0, 123, 456, 999} {
+			if !kept[id] {
+				t.Fatalf("resample test error: snapshot %d should be kept with %s", id, method)
+			}
+		}
+	}
+}
+
+func TestResample_Untouched_OK(t *testing.T) {
+	snapshots := sineSnapshots(1000)
+
+	if len(Resample(snapshots, 0, LTTB)) != 1000 || len(Resample(snapshots[:40], 50, LTTB)) != 40 {
+		t.Fatal("resample test error: expected the snapshots to be returned untouched")
+	}
+
+	// More snapshots to keep than points asked for
+	resampled := Resample(snapshots, 3, MinMax)
+	if len(resampled) != 4 {
+		t.Fatalf("resample test error: expected the 4 snapshots to keep, found %d", len(resampled))
+	}
+}
+
+func TestParseMethod_OK(t *testing.T) {
+	if method, err := ParseMethod(""); err != nil || method != LTTB {
+		t.Fatal("resample test error: expected lttb by default")
+	}
+	if method, err := ParseMethod("minmax"); err != nil || method != MinMax {
+		t.Fatal("resample test error: expected minmax")
+	}
+	if _, err := ParseMethod("random"); err == nil {
+		t.Fatal("resample test error: expected an error for an unknown method")
+	}
+}
+
+func TestResampleOnMassifLog_OK(t *testing.T) {
+
+	ol := parseArtifact(t)
+
+	// CAUTION: change in the artifacts should be taken into account here as well, there are 8 detailed snapshots
+	resampled := Resample(ol.Snapshots, 20, LTTB)
+	if len(resampled) > 20 {
+		t.Fatalf("resample test error: expected at most 20 snapshots, found %d", len(resampled))
+	}
+
+	detailed := 0
+	for _, ss := range resampled {
+		if ss.HeapTree != nil {
+			detailed++
+		}
+	}
+	if detailed != 8 {
+		t.Fatalf("resample test error: expected the 8 detailed snapshots to be kept, found %d", detailed)
+	}
+}
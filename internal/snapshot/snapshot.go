@@ -10,7 +10,15 @@ type Snapshot struct {
 	Time          int `json:"time"`
 	MemHeapB      int `json:"memHeapB"`
 	MemHeapExtraB int `json:"memHeapExtraB"`
-	MemStacksB    int `json:"memStacks"`
-	HeapTree      *heaptree.HeapTree
-	IsPeak        bool
+	MemStacksB    int `json:"memStacksB"`
+	// Massif only profiles stacks with --stacks=yes, MemStacksB is meaningless otherwise
+	StacksProfiled bool `json:"stacksProfiled"`
+	// Heap tree of the detailed snapshots, nil for the others
+	HeapTree *heaptree.HeapTree `json:"heapTree,omitempty"`
+	IsPeak   bool               `json:"isPeak"`
+}
+
+// Returns the stack bytes of the snapshot, and whether stacks were profiled at all, to tell unknown and zero stack usage apart
+func (ss *Snapshot) Stacks() (int, bool) {
+	return ss.MemStacksB, ss.StacksProfiled
 }
@@ -0,0 +1,145 @@
+package stacks
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+)
+
+// Define the stack versus heap memory of a snapshot
+type Breakdown struct {
+	SnapshotID int `json:"snapshotId"`
+	Time       int `json:"time"`
+	// Useful and extra heap bytes
+	HeapB   int `json:"heapB"`
+	StacksB int `json:"stacksB"`
+	// Share of the total memory held by the stacks, in percent
+	StacksPercent float64 `json:"stacksPercent"`
+}
+
+// Define the stack usage of a log
+type Report struct {
+	// Whether massif profiled the stacks. When false, the stack figures are unknown rather than zero
+	Profiled   bool        `json:"profiled"`
+	Breakdowns []Breakdown `json:"breakdowns"`
+	// Snapshot holding the most stack bytes, -1 when stacks are not profiled or the log has no snapshots
+	PeakSnapshotID int `json:"peakSnapshotId"`
+	PeakStacksB    int `json:"peakStacksB"`
+}
+
+// Breaks the memory of every snapshot of the log down into heap and stacks, and finds the stacks peak
+func Analyze(log *outlog.OutLog) Report {
+	report := Report{
+		Profiled:       log.StacksProfiled,
+		Breakdowns:     make([]Breakdown, 0, len(log.Snapshots)),
+		PeakSnapshotID: -1,
+	}
+
+	for _, ss := range log.Snapshots {
+		breakdown := Breakdown{
+			SnapshotID: ss.Id,
+			Time:       ss.Time,
+			HeapB:      ss.MemHeapB + ss.MemHeapExtraB,
+		}
+
+		if stacks, profiled := ss.Stacks(); profiled {
+			report.Profiled = true
+			breakdown.StacksB = stacks
+			if total := breakdown.HeapB + stacks; total > 0 {
+				breakdown.StacksPercent = 100 * float64(stacks) / float64(total)
+			}
+			if report.PeakSnapshotID == -1 || stacks > report.PeakStacksB {
+				report.PeakSnapshotID = ss.Id
+				report.PeakStacksB = stacks
+			}
+		}
+
+		report.Breakdowns = append(report.Breakdowns, breakdown)
+	}
+
+	return report
+}
+
+// Returns a one line summary of the stacks peak, e.g. for the summary outputs
+func (report Report) PeakSummary() string {
+	if !report.Profiled {
+		return "stacks not profiled (run massif with --stacks=yes)"
+	}
+	if report.PeakSnapshotID == -1 {
+		return "no snapshots"
+	}
+	return fmt.Sprintf("%d B at snapshot %d", report.PeakStacksB, report.PeakSnapshotID)
+}
+
+// Writes a text chart of the stacks versus heap memory over the snapshots, heap bytes drawn with '#' over stack bytes drawn with '='.
+// The chart is at most width columns wide, snapshots being sampled when there are more, and height rows tall
+func WriteChart(w io.Writer, report Report, width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("chart error: invalid size %dx%d", width, height)
+	}
+
+	breakdowns := report.Breakdowns
+	if len(breakdowns) > width {
+		sampled := make([]Breakdown, width)
+		for i := range sampled {
+			sampled[i] = breakdowns[i*len(breakdowns)/width]
+		}
+		breakdowns = sampled
+	}
+
+	maxTotal := 0
+	for _, breakdown := range breakdowns {
+		if total := breakdown.HeapB + breakdown.StacksB; total > maxTotal {
+			maxTotal = total
+		}
+	}
+
+	// Compute the rows each column fills, stacks first
+	stackRows := make([]int, len(breakdowns))
+	totalRows := make([]int, len(breakdowns))
+	if maxTotal > 0 {
+		for i, breakdown := range breakdowns {
+			totalRows[i] = (breakdown.HeapB + breakdown.StacksB) * height / maxTotal
+			stackRows[i] = breakdown.StacksB * height / maxTotal
+		}
+	}
+
+	var sb strings.Builder
+	label := fmt.Sprintf("%d B", maxTotal)
+	for row := height; row > 0; row-- {
+		if row == height {
+			sb.WriteString(label)
+		} else {
+			sb.WriteString(strings.Repeat(" ", len(label)))
+		}
+		sb.WriteString(" |")
+
+		for i := range breakdowns {
+			switch {
+			case row <= stackRows[i]:
+				sb.WriteByte('=')
+			case row <= totalRows[i]:
+				sb.WriteByte('#')
+			default:
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteString(strings.Repeat(" ", len(label)))
+	sb.WriteString(" +")
+	sb.WriteString(strings.Repeat("-", len(breakdowns)))
+	sb.WriteByte('\n')
+
+	if report.Profiled {
+		sb.WriteString("# heap  = stacks\n")
+	} else {
+		sb.WriteString("# heap  (stacks not profiled)\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,102 @@
+package stacks
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+)
+
+// A massif log recorded with --stacks=yes, the first snapshot holding no stack bytes yet
+const stacksLog = `desc: --stacks=yes
+cmd: ./prog
+time_unit: i
+#-----------
+snapshot=0
+#-----------
+time=0
+mem_heap_B=0
+mem_heap_extra_B=0
+mem_stacks_B=0
+heap_tree=empty
+#-----------
+snapshot=1
+#-----------
+time=100
+mem_heap_B=600
+mem_heap_extra_B=0
+mem_stacks_B=400
+heap_tree=empty
+#-----------
+snapshot=2
+#-----------
+time=200
+mem_heap_B=1000
+mem_heap_extra_B=0
+mem_stacks_B=1000
+heap_tree=empty
+`
+
+func TestAnalyze_Profiled_OK(t *testing.T) {
+	ol, err := digger.Parse(strings.NewReader(stacksLog))
+	if err != nil {
+		t.Fatalf("stacks test error: %v", err)
+	}
+
+	// Zero stack bytes in a profiled log are known to be zero
+	if stacks, profiled := ol.Snapshots[0].Stacks(); !profiled || stacks != 0 {
+		t.Fatalf("stacks test error: expected known zero stacks, found %d, %v", stacks, profiled)
+	}
+
+	report := Analyze(&ol)
+	if !report.Profiled || report.PeakSnapshotID != 2 || report.PeakStacksB != 1000 {
+		t.Fatalf("stacks test error: unexpected report %+v", report)
+	}
+	if report.Breakdowns[1].StacksPercent != 40 || report.Breakdowns[2].StacksPercent != 50 {
+		t.Fatalf("stacks test error: unexpected breakdowns %+v", report.Breakdowns)
+	}
+	if report.PeakSummary() != "1000 B at snapshot 2" {
+		t.Fatalf("stacks test error: unexpected peak summary %s", report.PeakSummary())
+	}
+
+	var buf bytes.Buffer
+	if err := WriteChart(&buf, report, 80, 4); err != nil {
+		t.Fatalf("stacks test error: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "2000 B |  #" || lines[3] != "       | #=" {
+		t.Fatalf("stacks test error: unexpected chart\n%s", buf.String())
+	}
+}
+
+func TestAnalyze_NotProfiled_OK(t *testing.T) {
+
+	// Stack bytes without --stacks=yes
+	ol, err := digger.Parse(strings.NewReader(strings.Replace(stacksLog, "--stacks=yes", "--massif-out-file=massif.out", 1)))
+	if err != nil {
+		t.Fatalf("stacks test error: %v", err)
+	}
+
+	// The stack bytes showing up mark every snapshot as profiled
+	if !ol.StacksProfiled || !ol.Snapshots[0].StacksProfiled {
+		t.Fatal("stacks test error: expected the stack bytes to mark the log as profiled")
+	}
+
+	// Without --stacks=yes nor stack bytes, stacks are unknown
+	noStacks := strings.NewReplacer("--stacks=yes", "--massif-out-file=massif.out", "mem_stacks_B=400", "mem_stacks_B=0", "mem_stacks_B=1000", "mem_stacks_B=0")
+	if ol, err = digger.Parse(strings.NewReader(noStacks.Replace(stacksLog))); err != nil {
+		t.Fatalf("stacks test error: %v", err)
+	}
+	if _, profiled := ol.Snapshots[2].Stacks(); profiled {
+		t.Fatal("stacks test error: expected unknown stacks")
+	}
+
+	report := Analyze(&ol)
+	if report.Profiled || report.PeakSnapshotID != -1 || report.Breakdowns[2].StacksB != 0 {
+		t.Fatalf("stacks test error: unexpected report %+v", report)
+	}
+	if report.PeakSummary() != "stacks not profiled (run massif with --stacks=yes)" {
+		t.Fatalf("stacks test error: unexpected peak summary %s", report.PeakSummary())
+	}
+}
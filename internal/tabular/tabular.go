@@ -0,0 +1,102 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/top"
+)
+
+// Separators of the tables
+const (
+	CSV = ','
+	TSV = '\t'
+)
+
+// Separator of the functions in the node paths
+const PathSeparator = ";"
+
+// Columns of the snapshot table
+var SnapshotColumns = []string{"snapshot_id", "time", "time_unit", "mem_heap_b", "mem_heap_extra_b", "mem_stacks_b", "total_b", "is_peak", "heap_tree"}
+
+// Columns of the node table
+var NodeColumns = []string{"snapshot_id", "node_path", "depth", "function", "object", "address", "bytes"}
+
+// Writes a table with one row per snapshot, with a header row, separated by the comma, e.g. CSV or TSV.
+// The stack bytes are left empty when massif did not profile them, and the heap tree is "empty", "detailed" or "peak", as in the massif log
+func WriteSnapshots(w io.Writer, log *outlog.OutLog, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.Write(SnapshotColumns)
+
+	for _, ss := range log.Snapshots {
+		stacks := ""
+		if ss.StacksProfiled {
+			stacks = strconv.Itoa(ss.MemStacksB)
+		}
+
+		tree := "empty"
+		switch {
+		case ss.HeapTree == nil:
+		case ss.IsPeak:
+			tree = "peak"
+		default:
+			tree = "detailed"
+		}
+
+		cw.Write([]string{
+			strconv.Itoa(ss.Id),
+			strconv.Itoa(ss.Time),
+			log.TimeUnit.String(),
+			strconv.Itoa(ss.MemHeapB),
+			strconv.Itoa(ss.MemHeapExtraB),
+			stacks,
+			strconv.Itoa(ss.MemHeapB + ss.MemHeapExtraB + ss.MemStacksB),
+			strconv.FormatBool(ss.IsPeak),
+			tree,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Writes a "long" table with one row per heap tree node of every detailed snapshot, with a header row, separated by the comma.
+// The node path lists the functions from the allocation functions down to the node, as labeled by top.Label and separated by PathSeparator, its depth being 0 for the root.
+// The object is empty for the frames massif resolved to a file and line
+func WriteNodes(w io.Writer, log *outlog.OutLog, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.Write(NodeColumns)
+
+	var path strings.Builder
+	for _, ss := range log.Snapshots {
+		id := strconv.Itoa(ss.Id)
+		ss.HeapTree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+			path.Reset()
+			for _, ancestor := range ancestors {
+				path.WriteString(top.Label(ancestor))
+				path.WriteString(PathSeparator)
+			}
+			path.WriteString(top.Label(node))
+
+			// The root description is its function, it is not an object
+			object := node.FuncFullDesc
+			if len(ancestors) == 0 {
+				object = ""
+			}
+
+			cw.Write([]string{id, path.String(), strconv.Itoa(len(ancestors)), node.Func, object, node.Address, strconv.Itoa(node.Memory)})
+		})
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,127 @@
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+func parseArtifact(t *testing.T) outlog.OutLog {
+	// Open the massif.out log in the artifacts
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("tabular test error: %v", err)
+	}
+	return log
+}
+
+func readTable(t *testing.T, content []byte, comma rune) [][]string {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = comma
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("tabular test error: %v", err)
+	}
+	return records
+}
+
+func TestWriteSnapshots_OK(t *testing.T) {
+	log := parseArtifact(t)
+
+	var b bytes.Buffer
+	if err := WriteSnapshots(&b, &log, CSV); err != nil {
+		t.Fatalf("tabular test error: %v", err)
+	}
+	records := readTable(t, b.Bytes(), CSV)
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	if len(records) != 61 || !reflect.DeepEqual(records[0], SnapshotColumns) {
+		t.Fatalf("tabular test error: unexpected table with %d rows and header %v", len(records), records[0])
+	}
+	if expected := []string{"45", "8755830", "i", "165527", "3017", "", "168544", "true", "peak"}; !reflect.DeepEqual(records[46], expected) {
+		t.Fatalf("tabular test error: expected the peak row %v, found %v", expected, records[46])
+	}
+
+	kinds := make(map[string]int)
+	for _, record := range records[1:] {
+		kinds[record[8]]++
+	}
+	if kinds["empty"] != 52 || kinds["detailed"] != 7 || kinds["peak"] != 1 {
+		t.Fatalf("tabular test error: unexpected heap tree kinds %v", kinds)
+	}
+}
+
+func TestWriteNodes_OK(t *testing.T) {
+	log := parseArtifact(t)
+
+	var b bytes.Buffer
+	if err := WriteNodes(&b, &log, TSV); err != nil {
+		t.Fatalf("tabular test error: %v", err)
+	}
+	records := readTable(t, b.Bytes(), TSV)
+
+	nodes := 0
+	for _, ss := range log.Snapshots {
+		ss.HeapTree.Walk(func(*heaptree.HeapTree, []*heaptree.HeapTree) { nodes++ })
+	}
+	if len(records) != nodes+1 || !reflect.DeepEqual(records[0], NodeColumns) {
+		t.Fatalf("tabular test error: unexpected table with %d rows out of %d nodes", len(records), nodes)
+	}
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	expected := [][]string{
+		{"45", "heap allocation functions", "0", "heap allocation functions", "", "root", "165527"},
+		{"45", "heap allocation functions;??? (in /usr/lib/x86_64-linux-gnu/libstdc++.so.6.0.30);call_init.part.0", "2", "call_init.part.0", "", "0x400647D", "72704"},
+	}
+	for _, row := range expected {
+		found := false
+		for _, record := range records {
+			found = found || reflect.DeepEqual(record, row)
+		}
+		if !found {
+			t.Fatalf("tabular test error: row %v not found", row)
+		}
+	}
+}
+
+func TestWrite_Stacks_OK(t *testing.T) {
+	log := outlog.OutLog{
+		TimeUnit: outlog.MS,
+		Snapshots: []snapshot.Snapshot{
+			{Id: 0, Time: 10, MemHeapB: 100, MemStacksB: 0, StacksProfiled: true},
+			{Id: 1, Time: 20, MemHeapB: 100, MemHeapExtraB: 8, MemStacksB: 400, StacksProfiled: true, HeapTree: &heaptree.HeapTree{Memory: 100, Frame: &heaptree.Frame{Address: "root", Func: "f, \"g\""}}},
+		},
+	}
+
+	var b bytes.Buffer
+	if err := WriteSnapshots(&b, &log, TSV); err != nil {
+		t.Fatalf("tabular test error: %v", err)
+	}
+	records := readTable(t, b.Bytes(), TSV)
+	if records[1][5] != "0" || records[2][5] != "400" || records[2][6] != "508" || records[2][8] != "detailed" || records[2][2] != "ms" {
+		t.Fatalf("tabular test error: unexpected table %v", records)
+	}
+
+	b.Reset()
+	if err := WriteNodes(&b, &log, CSV); err != nil {
+		t.Fatalf("tabular test error: %v", err)
+	}
+	records = readTable(t, b.Bytes(), CSV)
+	if len(records) != 2 || records[1][1] != "f, \"g\"" {
+		t.Fatalf("tabular test error: unexpected table %v", records)
+	}
+}
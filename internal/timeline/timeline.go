@@ -0,0 +1,149 @@
+package timeline
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+)
+
+// Define a snapshot time, interpreted in the time unit of its log
+type Time struct {
+	Raw  int
+	Unit outlog.TimeUnit
+}
+
+// Define a snapshot placed on the timeline of its log
+type Point struct {
+	SnapshotID int    `json:"snapshotId"`
+	Time       int    `json:"time"`
+	Label      string `json:"label"`
+	// Position of the snapshot on a normalized axis, from 0 at the first snapshot to 100 at the last one
+	Position      float64 `json:"position"`
+	MemHeapB      int     `json:"memHeapB"`
+	MemHeapExtraB int     `json:"memHeapExtraB"`
+	MemStacksB    int     `json:"memStacksB"`
+	Total         int     `json:"total"`
+	Detailed      bool    `json:"detailed"`
+	IsPeak        bool    `json:"isPeak"`
+}
+
+// Define the timeline of a log
+type Timeline struct {
+	Unit   string  `json:"unit"`
+	Points []Point `json:"points"`
+}
+
+// Builds the timeline of the log, with human readable labels and normalized positions so that logs recorded with different units can be compared
+func Build(log *outlog.OutLog) Timeline {
+	tl := Timeline{
+		Unit:   log.TimeUnit.String(),
+		Points: make([]Point, 0, len(log.Snapshots)),
+	}
+
+	for _, ss := range log.Snapshots {
+		tl.Points = append(tl.Points, Point{
+			SnapshotID:    ss.Id,
+			Time:          ss.Time,
+			Label:         Time{Raw: ss.Time, Unit: log.TimeUnit}.String(),
+			MemHeapB:      ss.MemHeapB,
+			MemHeapExtraB: ss.MemHeapExtraB,
+			MemStacksB:    ss.MemStacksB,
+			Total:         ss.MemHeapB + ss.MemHeapExtraB + ss.MemStacksB,
+			Detailed:      ss.HeapTree != nil,
+			IsPeak:        ss.IsPeak,
+		})
+	}
+
+	if len(tl.Points) == 0 {
+		return tl
+	}
+
+	first, last := tl.Points[0].Time, tl.Points[len(tl.Points)-1].Time
+	for i := range tl.Points {
+		tl.Points[i].Position = Normalize(tl.Points[i].Time, first, last)
+	}
+
+	return tl
+}
+
+// Maps a time onto a 0-100 axis going from first to last. A zero length span maps everything to 0
+func Normalize(t, first, last int) float64 {
+	if last == first {
+		return 0
+	}
+	return 100 * float64(t-first) / float64(last-first)
+}
+
+// Returns the time as a duration, only for logs recorded in milliseconds
+func (t Time) Duration() (time.Duration, error) {
+	if t.Unit != outlog.MS {
+		return 0, fmt.Errorf("timeline error: time unit %s is not a duration", t.Unit)
+	}
+	return time.Duration(t.Raw) * time.Millisecond, nil
+}
+
+// Returns the time as a number of executed instructions, only for logs recorded in instructions
+func (t Time) Instructions() (int, error) {
+	if t.Unit != outlog.I {
+		return 0, fmt.Errorf("timeline error: time unit %s is not instructions", t.Unit)
+	}
+	return t.Raw, nil
+}
+
+// Returns the time as a number of bytes allocated and deallocated, only for logs recorded in bytes
+func (t Time) Bytes() (int, error) {
+	if t.Unit != outlog.B {
+		return 0, fmt.Errorf("timeline error: time unit %s is not bytes", t.Unit)
+	}
+	return t.Raw, nil
+}
+
+// Formats the time human readably, e.g. "2.39 G instr", "1.2 s" or "3.5 MiB".
+// Times in an unresolved unit are formatted as plain numbers
+func (t Time) String() string {
+	switch t.Unit {
+	case outlog.I:
+		return scale(float64(t.Raw), 1000, []string{"instr", "k instr", "M instr", "G instr", "T instr"})
+	case outlog.B:
+		return FormatBytes(t.Raw)
+	case outlog.MS:
+		ms := float64(t.Raw)
+		switch {
+		case ms < 1000:
+			return fmt.Sprintf("%s ms", formatFloat(ms))
+		case ms < 60*1000:
+			return fmt.Sprintf("%s s", formatFloat(ms/1000))
+		case ms < 60*60*1000:
+			return fmt.Sprintf("%s min", formatFloat(ms/(60*1000)))
+		default:
+			return fmt.Sprintf("%s h", formatFloat(ms/(60*60*1000)))
+		}
+	default:
+		return strconv.Itoa(t.Raw)
+	}
+}
+
+// Formats a number of bytes human readably, e.g. "3.5 MiB"
+func FormatBytes(n int) string {
+	return scale(float64(n), 1024, []string{"B", "KiB", "MiB", "GiB", "TiB"})
+}
+
+// Divides the value by the base until it fits the largest suffix possible, and formats it with 3 significant digits
+func scale(value, base float64, suffixes []string) string {
+	i := 0
+	for i < len(suffixes)-1 && (value >= base || value <= -base) {
+		value /= base
+		i++
+	}
+	return fmt.Sprintf("%s %s", formatFloat(value), suffixes[i])
+}
+
+// Formats the value with 3 significant digits, without ever switching to the exponent notation
+func formatFloat(value float64) string {
+	if value >= 100 || value <= -100 {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(value, 'g', 3, 64)
+}
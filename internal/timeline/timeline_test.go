@@ -0,0 +1,106 @@
+package timeline
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("timeline test error: error reading from the massif.out: %v", err)
+	}
+	return log
+}
+
+func TestTimeString_OK(t *testing.T) {
+	type uTest struct {
+		time     Time
+		expected string
+	}
+
+	var uTests = []uTest{
+		{Time{0, outlog.I}, "0 instr"},
+		{Time{2390000000, outlog.I}, "2.39 G instr"},
+		{Time{8755830, outlog.I}, "8.76 M instr"},
+		{Time{1023, outlog.B}, "1023 B"},
+		{Time{3670016, outlog.B}, "3.5 MiB"},
+		{Time{350, outlog.MS}, "350 ms"},
+		{Time{1200, outlog.MS}, "1.2 s"},
+		{Time{90000, outlog.MS}, "1.5 min"},
+		{Time{7200000, outlog.MS}, "2 h"},
+		{Time{42, outlog.AUTO}, "42"},
+	}
+
+	for _, test := range uTests {
+		if output := test.time.String(); output != test.expected {
+			t.Fatalf("timeline test error: expected %s, found %s", test.expected, output)
+		}
+	}
+}
+
+func TestTimeConversions_OK(t *testing.T) {
+	if d, err := (Time{1200, outlog.MS}).Duration(); err != nil || d != 1200*time.Millisecond {
+		t.Fatalf("timeline test error: unexpected duration %v, %v", d, err)
+	}
+	if _, err := (Time{1200, outlog.I}).Duration(); err == nil {
+		t.Fatal("timeline test error: instructions should not convert to a duration")
+	}
+	if i, err := (Time{1200, outlog.I}).Instructions(); err != nil || i != 1200 {
+		t.Fatalf("timeline test error: unexpected instructions %d, %v", i, err)
+	}
+	if _, err := (Time{1200, outlog.AUTO}).Bytes(); err == nil {
+		t.Fatal("timeline test error: an unresolved time unit should not convert to bytes")
+	}
+}
+
+func TestBuild_Normalized_OK(t *testing.T) {
+
+	// Two logs recorded with different units and spans
+	inMS := outlog.OutLog{TimeUnit: outlog.MS, Snapshots: []snapshot.Snapshot{{Time: 100}, {Time: 600}, {Time: 1100}}}
+	inB := outlog.OutLog{TimeUnit: outlog.B, Snapshots: []snapshot.Snapshot{{Time: 0}, {Time: 4096}, {Time: 8192, MemHeapB: 10, MemHeapExtraB: 2, MemStacksB: 1}}}
+
+	msTimeline, bTimeline := Build(&inMS), Build(&inB)
+	for i := range msTimeline.Points {
+		if msTimeline.Points[i].Position != bTimeline.Points[i].Position {
+			t.Fatalf("timeline test error: expected both timelines to align at %d, found %f and %f", i, msTimeline.Points[i].Position, bTimeline.Points[i].Position)
+		}
+	}
+
+	if msTimeline.Points[1].Position != 50 || msTimeline.Points[2].Position != 100 {
+		t.Fatal("timeline test error: unexpected positions")
+	}
+	if msTimeline.Unit != "ms" || msTimeline.Points[2].Label != "1.1 s" || bTimeline.Points[1].Label != "4 KiB" {
+		t.Fatal("timeline test error: unexpected labels")
+	}
+	if bTimeline.Points[2].Total != 13 {
+		t.Fatalf("timeline test error: expected a total of 13, found %d", bTimeline.Points[2].Total)
+	}
+}
+
+func TestBuildOnMassifLog_OK(t *testing.T) {
+
+	ol := parseArtifact(t)
+
+	// CAUTION: change in the artifacts should be taken into account here as well
+	tl := Build(&ol)
+	if len(tl.Points) != 60 || tl.Unit != "i" {
+		t.Fatalf("timeline test error: expected 60 points in instructions, found %d in %s", len(tl.Points), tl.Unit)
+	}
+	if peak := tl.Points[45]; !peak.IsPeak || !peak.Detailed || peak.Label != "8.76 M instr" {
+		t.Fatalf("timeline test error: unexpected peak point %+v", peak)
+	}
+}
@@ -0,0 +1,88 @@
+package top
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Define the change of the inclusive bytes of a function between two detailed snapshots
+type Delta struct {
+	Func   string `json:"func"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Delta  int    `json:"delta"`
+}
+
+// Compares the sites of two detailed snapshots, e.g. the first and last ones of a run, or the peaks of two runs.
+// Returns the functions whose inclusive bytes changed, sorted by decreasing absolute change, growth first on ties
+func Diff(before, after *snapshot.Snapshot) []Delta {
+	index := make(map[string]int)
+	var deltas []Delta
+
+	for _, site := range Sites(before) {
+		index[site.Func] = len(deltas)
+		deltas = append(deltas, Delta{Func: site.Func, Before: site.InclusiveBytes})
+	}
+	for _, site := range Sites(after) {
+		i, ok := index[site.Func]
+		if !ok {
+			i = len(deltas)
+			index[site.Func] = i
+			deltas = append(deltas, Delta{Func: site.Func})
+		}
+		deltas[i].After = site.InclusiveBytes
+	}
+
+	changed := deltas[:0]
+	for _, delta := range deltas {
+		if delta.Delta = delta.After - delta.Before; delta.Delta != 0 {
+			changed = append(changed, delta)
+		}
+	}
+
+	sort.SliceStable(changed, func(i, j int) bool {
+		if abs(changed[i].Delta) != abs(changed[j].Delta) {
+			return abs(changed[i].Delta) > abs(changed[j].Delta)
+		}
+		if changed[i].Delta != changed[j].Delta {
+			return changed[i].Delta > changed[j].Delta
+		}
+		return changed[i].Func < changed[j].Func
+	})
+
+	return changed
+}
+
+// Writes the deltas to the writer as an indented JSON array
+func WriteDiffJSON(w io.Writer, deltas []Delta) error {
+	if deltas == nil {
+		deltas = []Delta{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(deltas)
+}
+
+// Writes the deltas to the writer as an aligned text table
+func WriteDiffTable(w io.Writer, deltas []Delta) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "BEFORE\tAFTER\tDELTA\t\tFUNC")
+	for _, delta := range deltas {
+		fmt.Fprintf(tw, "%d\t%d\t%+d\t\t%s\n", delta.Before, delta.After, delta.Delta, delta.Func)
+	}
+
+	return tw.Flush()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,128 @@
+package top
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Define the allocations of a function, folded across all the branches of a heap tree
+type Site struct {
+	Func string `json:"func"`
+	// Bytes allocated by the function and everything it calls
+	InclusiveBytes int `json:"inclusiveBytes"`
+	// Bytes allocated by the function directly calling an allocation function
+	SelfBytes int `json:"selfBytes"`
+	// Number of distinct call paths going through the function
+	CallPaths int `json:"callPaths"`
+	// Share of the snapshot heap held by the function, inclusive bytes wise
+	Percent float64 `json:"percent"`
+}
+
+// Folds identical functions across the heap tree of a detailed snapshot, and returns the resulting sites sorted by decreasing inclusive bytes.
+// Returns a nil slice if the snapshot has no heap tree
+func Sites(ss *snapshot.Snapshot) []Site {
+	if ss.HeapTree == nil {
+		return nil
+	}
+
+	total := ss.MemHeapB
+	if total == 0 {
+		total = ss.HeapTree.Memory
+	}
+
+	index := make(map[string]int)
+	var sites []Site
+
+	ss.HeapTree.Walk(func(node *heaptree.HeapTree, ancestors []*heaptree.HeapTree) {
+		// The root stands for the allocation functions themselves
+		if len(ancestors) == 0 {
+			return
+		}
+
+		label := Label(node)
+
+		// Recursive calls are only accounted for once per path, at the occurrence closest to the allocation function
+		for _, ancestor := range ancestors[1:] {
+			if Label(ancestor) == label {
+				return
+			}
+		}
+
+		i, ok := index[label]
+		if !ok {
+			i = len(sites)
+			index[label] = i
+			sites = append(sites, Site{Func: label})
+		}
+
+		sites[i].InclusiveBytes += node.Memory
+		sites[i].CallPaths++
+		if len(ancestors) == 1 {
+			sites[i].SelfBytes += node.Memory
+		}
+	})
+
+	for i := range sites {
+		if total > 0 {
+			sites[i].Percent = 100 * float64(sites[i].InclusiveBytes) / float64(total)
+		}
+	}
+
+	sort.SliceStable(sites, func(i, j int) bool {
+		if sites[i].InclusiveBytes != sites[j].InclusiveBytes {
+			return sites[i].InclusiveBytes > sites[j].InclusiveBytes
+		}
+		if sites[i].SelfBytes != sites[j].SelfBytes {
+			return sites[i].SelfBytes > sites[j].SelfBytes
+		}
+		return sites[i].Func < sites[j].Func
+	})
+
+	return sites
+}
+
+// Returns the n sites allocating the most in the snapshot. A non positive n returns them all
+func TopN(ss *snapshot.Snapshot, n int) []Site {
+	sites := Sites(ss)
+	if n > 0 && n < len(sites) {
+		sites = sites[:n]
+	}
+	return sites
+}
+
+// Returns the name a heap tree node is folded under.
+// Unresolved functions are told apart by the object they belong to, when known
+func Label(node *heaptree.HeapTree) string {
+	if node.Func == "???" && node.FuncFullDesc != "" {
+		return fmt.Sprintf("??? (in %s)", node.FuncFullDesc)
+	}
+	return node.Func
+}
+
+// Writes the sites to the writer as an indented JSON array
+func WriteJSON(w io.Writer, sites []Site) error {
+	if sites == nil {
+		sites = []Site{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sites)
+}
+
+// Writes the sites to the writer as an aligned text table
+func WriteTable(w io.Writer, sites []Site) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "INCLUSIVE\tSELF\tPATHS\t%HEAP\t\tFUNC")
+	for _, site := range sites {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%.2f%%\t\t%s\n", site.InclusiveBytes, site.SelfBytes, site.CallPaths, site.Percent, site.Func)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,146 @@
+package top
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/MohamTahaB/massif-miner/internal/digger"
+	"github.com/MohamTahaB/massif-miner/internal/heaptree"
+	"github.com/MohamTahaB/massif-miner/internal/outlog"
+	"github.com/MohamTahaB/massif-miner/internal/snapshot"
+)
+
+// Parses the massif.out log in the artifacts
+func parseArtifact(t *testing.T) outlog.OutLog {
+	file, err := os.Open("../utils/artifacts/massif.out.log")
+	if err != nil {
+		t.Fatalf("error opening the massif.out log: %v", err)
+	}
+
+	defer file.Close()
+
+	log, err := digger.Parse(file)
+	if err != nil {
+		t.Fatalf("top test error: error reading from the massif.out: %v", err)
+	}
+	return log
+}
+
+func TestSites_Recursion_OK(t *testing.T) {
+
+	// recurse() calls itself before allocating, it should only be accounted for once
+	ss := snapshot.Snapshot{
+		MemHeapB: 100,
+		HeapTree: &heaptree.HeapTree{
+			Frame:  &heaptree.Frame{Address: "root", Func: "heap allocation functions"},
+			Memory: 100,
+			HeapAllocationLeafs: []*heaptree.HeapTree{
+				{Frame: &heaptree.Frame{Address: "0x1", Func: "recurse()"}, Memory: 60, HeapAllocationLeafs: []*heaptree.HeapTree{
+					{Frame: &heaptree.Frame{Address: "0x2", Func: "recurse()"}, Memory: 60, HeapAllocationLeafs: []*heaptree.HeapTree{
+						{Frame: &heaptree.Frame{Address: "0x3", Func: "main"}, Memory: 60},
+					}},
+				}},
+				{Frame: &heaptree.Frame{Address: "0x4", Func: "helper()"}, Memory: 40, HeapAllocationLeafs: []*heaptree.HeapTree{
+					{Frame: &heaptree.Frame{Address: "0x5", Func: "recurse()"}, Memory: 40, HeapAllocationLeafs: []*heaptree.HeapTree{
+						{Frame: &heaptree.Frame{Address: "0x3", Func: "main"}, Memory: 40},
+					}},
+				}},
+			},
+		},
+	}
+
+	sites := Sites(&ss)
+	if len(sites) != 3 {
+		t.Fatalf("top test error: expected 3 sites, found %d", len(sites))
+	}
+
+	// recurse() and main share the same inclusive bytes, ties are broken by the self bytes
+	if sites[0].Func != "recurse()" || sites[0].InclusiveBytes != 100 || sites[0].SelfBytes != 60 || sites[0].CallPaths != 2 {
+		t.Fatalf("top test error: unexpected first site %+v", sites[0])
+	}
+	if sites[1].Func != "main" || sites[1].InclusiveBytes != 100 || sites[1].SelfBytes != 0 || sites[1].Percent != 100 {
+		t.Fatalf("top test error: unexpected second site %+v", sites[1])
+	}
+	if sites[2].Func != "helper()" || sites[2].InclusiveBytes != 40 || sites[2].SelfBytes != 40 {
+		t.Fatalf("top test error: unexpected third site %+v", sites[2])
+	}
+
+	if len(TopN(&ss, 1)) != 1 || len(TopN(&ss, 0)) != 3 {
+		t.Fatal("top test error: unexpected number of top sites")
+	}
+}
+
+func TestTopNOnMassifLog_OK(t *testing.T) {
+
+	ol := parseArtifact(t)
+
+	// CAUTION: change in the artifacts should be taken into account here as well, snapshot 45 is the peak
+	sites := TopN(&ol.Snapshots[45], 3)
+	if len(sites) != 3 {
+		t.Fatalf("top test error: expected 3 sites, found %d", len(sites))
+	}
+
+	if sites[0].Func != "allocateAndDeallocate()" || sites[0].InclusiveBytes != 92823 || sites[0].SelfBytes != 90775 || sites[0].CallPaths != 2 {
+		t.Fatalf("top test error: unexpected first site %+v", sites[0])
+	}
+	if sites[2].Func != "??? (in /usr/lib/x86_64-linux-gnu/libstdc++.so.6.0.30)" || sites[2].SelfBytes != 72704 {
+		t.Fatalf("top test error: unexpected third site %+v", sites[2])
+	}
+
+	// Snapshots without a heap tree have no sites
+	if sites := Sites(&ol.Snapshots[46]); sites != nil {
+		t.Fatalf("top test error: expected no sites for a regular snapshot, found %d", len(sites))
+	}
+
+	// Check the JSON output
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, sites); err != nil {
+		t.Fatalf("top test error: %v", err)
+	}
+	var decoded []Site
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 3 || decoded[0] != sites[0] {
+		t.Fatalf("top test error: unexpected JSON output %s", buf.String())
+	}
+
+	// Check the table output: a header and a line per site
+	buf.Reset()
+	if err := WriteTable(&buf, sites); err != nil {
+		t.Fatalf("top test error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "allocateAndDeallocate()") {
+		t.Fatalf("top test error: unexpected table output\n%s", buf.String())
+	}
+}
+
+func TestDiffOnMassifLog_OK(t *testing.T) {
+
+	log := parseArtifact(t)
+
+	// CAUTION: change in the artifacts should be taken into account here as well, the first detailed snapshot is 4, the peak 45 and the last one 58
+	deltas := Diff(&log.Snapshots[4], &log.Snapshots[45])
+	if len(deltas) != 7 {
+		t.Fatalf("diff test error: expected 7 deltas, found %d", len(deltas))
+	}
+	// main and allocateAndDeallocate() grow by the same amount, ties are broken by name
+	if deltas[0].Func != "allocateAndDeallocate()" || deltas[0].Before != 21776 || deltas[0].After != 92823 || deltas[0].Delta != 71047 {
+		t.Fatalf("diff test error: unexpected first delta %+v", deltas[0])
+	}
+	if deltas[2].Before != 0 || deltas[2].Delta != 2048 {
+		t.Fatalf("diff test error: unexpected new site %+v", deltas[2])
+	}
+
+	// Shrinking sites are reported as well, unchanged ones are not
+	deltas = Diff(&log.Snapshots[45], &log.Snapshots[58])
+	if len(deltas) != 2 || deltas[1].Func != "main" || deltas[1].Delta != -41350 {
+		t.Fatalf("diff test error: unexpected deltas %+v", deltas)
+	}
+
+	var table bytes.Buffer
+	if err := WriteDiffTable(&table, deltas); err != nil || !strings.Contains(table.String(), "-41350") {
+		t.Fatalf("diff test error: unexpected table %q, %v", table.String(), err)
+	}
+}
@@ -2,29 +2,46 @@ package utils
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 )
 
 // Extracts the value from a line of the form "label=value"
 // Returns the value or (xor) an error if not found
 func ExtractValueOf(label string, line string, valueIsANumber bool) (string, error) {
-
-	// Define whether the value is a number or a string
-	valueRegex := "."
-	if valueIsANumber {
-		valueRegex = `\d`
+	value, ok := ExtractValue(label, line, valueIsANumber)
+	if !ok {
+		return "", fmt.Errorf("value of %s not found", label)
 	}
-	pattern := fmt.Sprintf(`%s=(%s+)$`, label, valueRegex)
-	regex := regexp.MustCompile(pattern)
 
-	stringSubmatches := regex.FindStringSubmatch(line)
+	return value, nil
+}
 
-	if len(stringSubmatches) < 2 {
-		return "", fmt.Errorf("unexpected regex issue")
+// Extracts the value running to the end of a line of the form "label=value", from the first label occurrence followed by a valid value.
+// Numbers are made of ascii digits only. Works on strings and byte slices alike, without allocating
+func ExtractValue[T string | []byte](label string, line T, valueIsANumber bool) (T, bool) {
+	for i := 0; i+len(label) < len(line); i++ {
+		if line[i+len(label)] != '=' || string(line[i:i+len(label)]) != label {
+			continue
+		}
+
+		value := line[i+len(label)+1:]
+		if len(value) > 0 && (!valueIsANumber || IsDigits(value)) {
+			return value, true
+		}
 	}
 
-	return stringSubmatches[1], nil
+	var none T
+	return none, false
+}
+
+// Checks whether the input is a non empty run of ascii digits
+func IsDigits[T string | []byte](s T) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
 }
 
 func LeadingSpaces(s string) (string, int) {
@@ -36,3 +53,31 @@ func LeadingSpaces(s string) (string, int) {
 
 	return "", len(s)
 }
+
+// Fits ys against xs with least squares, and returns the slope.
+// Returns 0 when there are less than two points or when all xs are equal
+func LeastSquaresSlope(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	if n < 2 || len(ys) != len(xs) {
+		return 0
+	}
+
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX float64
+	for i := range xs {
+		dx := xs[i] - meanX
+		cov += dx * (ys[i] - meanY)
+		varX += dx * dx
+	}
+
+	if varX == 0 {
+		return 0
+	}
+	return cov / varX
+}